@@ -0,0 +1,180 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrNoActivation is returned by ListenFromActivation when the process was not passed any
+// sockets by its service manager.
+var ErrNoActivation = errors.New("no socket activation fds")
+
+// activationFDStart is the first fd passed by the systemd socket activation protocol.
+const activationFDStart = 3
+
+// ListenFromActivation multiplexes the sockets passed to the process through systemd style
+// socket activation, as described by LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES. The variables
+// are unset so child processes do not inherit the sockets. The returned multilistener owns the
+// sockets. Only stream sockets are supported. Info reports the LISTEN_FDNAMES name of each
+// socket as its RequestedAddr, or its address when it has none.
+func ListenFromActivation(opts ...Option) (*MultiListener, error) {
+	listeners, names, err := activationListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMultiListener(opts...)
+	if err != nil {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	for i, nL := range listeners {
+		m.register(nL, nL.Addr().Network(), names[i], !m.opts.disowned)
+	}
+
+	for _, l := range m.listeners {
+		m.start(l)
+	}
+
+	m.run()
+
+	return m.handle(), nil
+}
+
+// ListenFromSystemd multiplexes the sockets passed through socket activation like
+// ListenFromActivation, along with the addresses, which are bound like Listen. This lets a
+// service get privileged ports from systemd while binding the rest itself. Without activated
+// sockets only the addresses are bound, and ErrNoActivation is returned if there are none.
+// If any bind fails the activated sockets are closed as well. Activated sockets are named in
+// Info like ListenFromActivation.
+func ListenFromSystemd(addresses map[string][]string, opts ...Option) (*MultiListener, error) {
+	listeners, names, err := activationListeners()
+	if err != nil && (!errors.Is(err, ErrNoActivation) || len(addresses) == 0) {
+		return nil, err
+	}
+
+	m, err := newMultiListener(opts...)
+	if err == nil {
+		err = checkNetworks(addresses)
+	}
+	if err != nil {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+
+	m.mut.Lock()
+	for i, nL := range listeners {
+		m.register(nL, nL.Addr().Network(), names[i], !m.opts.disowned)
+	}
+	m.mut.Unlock()
+
+	err = m.listenPairs(pairsOf(addresses), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.handle(), nil
+}
+
+// activationListeners returns the sockets passed to the process as listeners, along with the
+// LISTEN_FDNAMES name of each, or its address if it has none.
+func activationListeners() ([]net.Listener, []string, error) {
+	files, names, err := activationFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listeners, err := fileListeners(files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, l := range listeners {
+		if names[i] == "" {
+			names[i] = l.Addr().String()
+		}
+	}
+
+	return listeners, names, nil
+}
+
+// fileListeners turns inherited files into listeners, closing the files either way.
+func fileListeners(files []*os.File) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(files))
+
+	for _, f := range files {
+		// FileListener dups the fd, so the inherited one is closed either way.
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			for _, f := range files[len(listeners)+1:] {
+				f.Close()
+			}
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// activationFiles returns the files passed to the process along with their LISTEN_FDNAMES
+// names, empty for unnamed ones, and unsets the activation variables.
+func activationFiles() ([]*os.File, []string, error) {
+	pid := os.Getenv("LISTEN_PID")
+	fds := os.Getenv("LISTEN_FDS")
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid == "" || fds == "" {
+		return nil, nil, ErrNoActivation
+	}
+
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, fmt.Errorf("%w: LISTEN_PID %s is not this process", ErrNoActivation, pid)
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, nil, fmt.Errorf("%w: invalid LISTEN_FDS %q", ErrNoActivation, fds)
+	}
+
+	if n == 0 {
+		return nil, nil, ErrNoActivation
+	}
+
+	files := make([]*os.File, 0, n)
+	named := make([]string, n)
+
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(activationFDStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+			named[i] = names[i]
+		}
+
+		files = append(files, os.NewFile(uintptr(activationFDStart+i), name))
+	}
+
+	return files, named, nil
+}
@@ -0,0 +1,185 @@
+//go:build !windows && !plan9
+
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+)
+
+// TestListenFromActivation tests multiplexing sockets passed like systemd does by running
+// the test binary as the activated process.
+func TestListenFromActivation(t *testing.T) {
+	if os.Getenv("MULTILISTENER_ACTIVATION_HELPER") != "" {
+		activationHelper()
+		return
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal("error getting listener file", err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestListenFromActivation$")
+	cmd.Env = append(os.Environ(),
+		"MULTILISTENER_ACTIVATION_HELPER="+l.Addr().String(),
+		"LISTEN_FDS=1",
+		"LISTEN_FDNAMES=http",
+	)
+	cmd.ExtraFiles = []*os.File{f}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Error("activated process failed", err, string(out))
+	}
+}
+
+// activationHelper runs in the activated process and exits with its result.
+func activationHelper() {
+	// The parent can not know the pid in advance, unlike a service manager.
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	m, err := ListenFromActivation()
+	if err != nil {
+		println("error when listening from activation", err.Error())
+		os.Exit(1)
+	}
+	defer m.Close()
+
+	if m.Addr().String() != os.Getenv("MULTILISTENER_ACTIVATION_HELPER") {
+		println("unexpected address", m.Addr().String())
+		os.Exit(1)
+	}
+
+	if info := m.Info(); info[0].RequestedAddr != "http" {
+		println("the socket should be named after LISTEN_FDNAMES", info[0].RequestedAddr)
+		os.Exit(1)
+	}
+
+	if os.Getenv("LISTEN_FDS") != "" {
+		println("activation variables should be unset")
+		os.Exit(1)
+	}
+}
+
+// TestListenFromActivationNone tests that a process without activated sockets gets ErrNoActivation.
+func TestListenFromActivationNone(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+
+	_, err := ListenFromActivation()
+	if !errors.Is(err, ErrNoActivation) {
+		t.Error("zero fds should return ErrNoActivation", err)
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	_, err = ListenFromActivation()
+	if !errors.Is(err, ErrNoActivation) {
+		t.Error("fds for another process should return ErrNoActivation", err)
+	}
+}
+
+// TestListenFromSystemd tests merging activated sockets with bound addresses by running the
+// test binary as the activated process.
+func TestListenFromSystemd(t *testing.T) {
+	if os.Getenv("MULTILISTENER_SYSTEMD_HELPER") != "" {
+		systemdHelper()
+		return
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal("error getting listener file", err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestListenFromSystemd$")
+	cmd.Env = append(os.Environ(),
+		"MULTILISTENER_SYSTEMD_HELPER="+l.Addr().String(),
+		"LISTEN_FDS=1",
+	)
+	cmd.ExtraFiles = []*os.File{f}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Error("activated process failed", err, string(out))
+	}
+}
+
+// systemdHelper runs in the activated process and exits with its result.
+func systemdHelper() {
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	m, err := ListenFromSystemd(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		println("error when listening from systemd", err.Error())
+		os.Exit(1)
+	}
+	defer m.Close()
+
+	addrs := m.Addresses()
+	if len(addrs) != 2 {
+		println("unexpected number of listeners", len(addrs))
+		os.Exit(1)
+	}
+
+	found := false
+	for _, addr := range addrs {
+		found = found || addr.String() == os.Getenv("MULTILISTENER_SYSTEMD_HELPER")
+	}
+
+	if !found {
+		println("the activated socket is missing", m.String())
+		os.Exit(1)
+	}
+
+	for _, info := range m.Info() {
+		if info.ResolvedAddr == os.Getenv("MULTILISTENER_SYSTEMD_HELPER") && info.RequestedAddr != info.ResolvedAddr {
+			println("an unnamed socket should be requested as its address", info.RequestedAddr)
+			os.Exit(1)
+		}
+	}
+}
+
+// TestListenFromSystemdNone tests that without activated sockets only the addresses are bound.
+func TestListenFromSystemdNone(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "")
+
+	_, err := ListenFromSystemd(nil)
+	if !errors.Is(err, ErrNoActivation) {
+		t.Error("nothing to listen on should return ErrNoActivation", err)
+	}
+
+	m, err := ListenFromSystemd(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("the addresses should be bound without activation", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	if len(m.Addresses()) != 1 {
+		t.Error("only the addresses should be bound", m.Addresses())
+	}
+}
@@ -0,0 +1,59 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// initialBackoff is the first delay of WithAcceptBackoff, as in net/http.
+const initialBackoff = 5 * time.Millisecond
+
+// WithAcceptBackoff retries temporary accept errors, such as ECONNABORTED, or EMFILE without
+// WithFDExhaustionCooldown, after a delay instead of returning them from Accept, like
+// http.Server does. The delay starts at 5ms and doubles with every error in a row up to max,
+// and is reset once accept succeeds. Retried errors are still reported through the error
+// hook. Other errors are returned from Accept as usual.
+func WithAcceptBackoff(max time.Duration) Option {
+	return func(o *options) {
+		o.backoffMax = max
+	}
+}
+
+// backOff reports whether the accept error err of l is temporary and, if so, waits out the
+// next delay after delay, which it updates. It returns early if the multilistener is closed
+// or l removed.
+func (m *multiListener) backOff(l *managedListener, err error, delay *time.Duration) bool {
+	if m.opts.backoffMax <= 0 || !isTemporary(err) {
+		return false
+	}
+
+	*delay = min(max(2**delay, initialBackoff), m.opts.backoffMax)
+	m.report(l.addr, fmt.Errorf("%w; retrying in %s", err, *delay))
+
+	timer := m.opts.clock.NewTimer(*delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-m.stop:
+	case <-l.quit:
+	}
+	return true
+}
+
+// isTemporary reports whether the accept error err is worth retrying.
+func isTemporary(err error) bool {
+	if isFDExhausted(err) {
+		return true
+	}
+
+	for _, errno := range temporaryErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
@@ -0,0 +1,100 @@
+package multilistener
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// benchListener is a listener that hands out the same conn forever, so benchmarks only
+// measure the multilistener's own accept path rather than the network stack.
+type benchListener struct {
+	conn net.Conn
+	done chan struct{}
+}
+
+// Accept implements net.Listener.
+func (b *benchListener) Accept() (net.Conn, error) {
+	select {
+	case <-b.done:
+		return nil, net.ErrClosed
+	default:
+		return b.conn, nil
+	}
+}
+
+// Close implements net.Listener.
+func (b *benchListener) Close() error {
+	close(b.done)
+	return nil
+}
+
+// Addr implements net.Listener.
+func (b *benchListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+}
+
+// BenchmarkAccept measures the overhead the multilistener adds to each accept.
+func BenchmarkAccept(b *testing.B) {
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+
+	m, err := New([]net.Listener{&benchListener{conn: s, done: make(chan struct{})}})
+	if err != nil {
+		b.Fatal("error creating multilistener", err)
+	}
+	defer m.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := m.Accept()
+		if err != nil {
+			b.Fatal("error accepting", err)
+		}
+	}
+}
+
+// slowListener is a benchListener whose Accept takes a while, like one doing work per conn.
+type slowListener struct {
+	benchListener
+	delay time.Duration
+}
+
+// Accept implements net.Listener.
+func (s *slowListener) Accept() (net.Conn, error) {
+	time.Sleep(s.delay)
+	return s.benchListener.Accept()
+}
+
+// BenchmarkAcceptWorkers measures accept throughput from a slow listener as accept
+// routines are added.
+func BenchmarkAcceptWorkers(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			c, s := net.Pipe()
+			defer c.Close()
+			defer s.Close()
+
+			l := &slowListener{benchListener: benchListener{conn: s, done: make(chan struct{})}, delay: 50 * time.Microsecond}
+
+			m, err := New([]net.Listener{l}, WithAcceptWorkers(workers))
+			if err != nil {
+				b.Fatal("error creating multilistener", err)
+			}
+			defer m.Close()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := m.Accept()
+				if err != nil {
+					b.Fatal("error accepting", err)
+				}
+			}
+		})
+	}
+}
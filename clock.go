@@ -0,0 +1,71 @@
+package multilistener
+
+import "time"
+
+// clock is the source of time for every timeout, so tests can control it.
+type clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) clockTimer
+	NewTicker(d time.Duration) clockTicker
+	AfterFunc(d time.Duration, f func()) clockTimer
+}
+
+// clockTimer is a *time.Timer from a clock. C is nil for timers created by AfterFunc.
+type clockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// clockTicker is a *time.Ticker from a clock.
+type clockTicker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the clock backed by the time package.
+type realClock struct{}
+
+// Now implements clock.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer implements clock.
+func (realClock) NewTimer(d time.Duration) clockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// NewTicker implements clock.
+func (realClock) NewTicker(d time.Duration) clockTicker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// AfterFunc implements clock.
+func (realClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+// C implements clockTimer.
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+// C implements clockTicker.
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// withClock replaces the real clock, for tests.
+func withClock(c clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
@@ -0,0 +1,223 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when Advance is called.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a timer or ticker of a fakeClock.
+type fakeTimer struct {
+	clock  *fakeClock
+	when   time.Time
+	period time.Duration
+	c      chan time.Time
+	f      func()
+}
+
+// fakeTicker is a fakeTimer that repeats.
+type fakeTicker struct {
+	*fakeTimer
+}
+
+// Stop implements clockTicker.
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+// newFakeClock creates a fake clock set to an arbitrary time.
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1_000_000, 0)}
+}
+
+// Now implements clock.
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements clock.
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	return c.add(d, 0, nil)
+}
+
+// NewTicker implements clock.
+func (c *fakeClock) NewTicker(d time.Duration) clockTicker {
+	return fakeTicker{c.add(d, d, nil)}
+}
+
+// AfterFunc implements clock.
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) clockTimer {
+	return c.add(d, 0, f)
+}
+
+// add registers a timer due in d that repeats every period, if set, and calls f instead of
+// sending on its channel, if set.
+func (c *fakeClock) add(d time.Duration, period time.Duration, f func()) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), period: period, c: make(chan time.Time, 1), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that comes due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	due := []*fakeTimer{}
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+
+		due = append(due, t)
+		if t.period > 0 {
+			t.when = now.Add(t.period)
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	for _, t := range due {
+		if t.f != nil {
+			go t.f()
+			continue
+		}
+
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+}
+
+// awaitTimers blocks until at least n timers are pending, so that Advance is not called
+// before the code under test has started waiting.
+func (c *fakeClock) awaitTimers(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		pending := len(c.timers)
+		c.mu.Unlock()
+
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timers were not started", n)
+}
+
+// C implements clockTimer.
+func (t *fakeTimer) C() <-chan time.Time {
+	if t.f != nil {
+		return nil
+	}
+	return t.c
+}
+
+// Stop implements clockTimer.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// TestAcceptTimeoutClock tests AcceptTimeout against a fake clock.
+func TestAcceptTimeoutClock(t *testing.T) {
+	clock := newFakeClock()
+
+	m, err := New(nil, withClock(clock))
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.AcceptTimeout(time.Hour)
+		done <- err
+	}()
+
+	clock.awaitTimers(t, 1)
+	clock.Advance(time.Hour)
+
+	if err := <-done; !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("accept should time out once the clock passes the timeout", err)
+	}
+}
+
+// TestSetDeadline tests that blocked accepts time out once the deadline passes, and accept
+// again once it is cleared.
+func TestSetDeadline(t *testing.T) {
+	clock := newFakeClock()
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, withClock(clock))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	m.(*MultiListener).SetDeadline(clock.Now().Add(time.Minute))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Accept()
+		done <- err
+	}()
+
+	clock.awaitTimers(t, 1)
+	clock.Advance(time.Minute)
+
+	var netErr net.Error
+	if err := <-done; !errors.Is(err, os.ErrDeadlineExceeded) || !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Error("accept should time out once the deadline passed", err)
+	}
+
+	if _, err := m.Accept(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("accept should keep timing out until the deadline is cleared", err)
+	}
+
+	m.(*MultiListener).SetDeadline(time.Time{})
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("accept should succeed once the deadline is cleared", err)
+	}
+	conn.Close()
+}
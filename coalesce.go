@@ -0,0 +1,109 @@
+package multilistener
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// WithErrorCoalescing reports an error that a listener repeats within window of its first
+// report only once. When the window closes, or the listener reports a different error, the
+// repeats are reported as a single *RepeatedError carrying how many were suppressed.
+func WithErrorCoalescing(window time.Duration) Option {
+	return func(o *options) {
+		o.coalesce = window
+	}
+}
+
+// RepeatedError summarizes repeats of Err that WithErrorCoalescing suppressed.
+type RepeatedError struct {
+	Err   error
+	Count int
+}
+
+// Error implements error.
+func (e *RepeatedError) Error() string {
+	return fmt.Sprintf("%s (repeated %d times)", e.Err, e.Count)
+}
+
+// Unwrap returns the repeated error.
+func (e *RepeatedError) Unwrap() error {
+	return e.Err
+}
+
+// coalescedError is the error a listener is currently repeating.
+type coalescedError struct {
+	err   error
+	msg   string
+	count int
+	timer clockTimer
+}
+
+// summary returns the report for the suppressed repeats, or nil if there were none.
+func (c *coalescedError) summary() error {
+	if c.count == 0 {
+		return nil
+	}
+	return &RepeatedError{Err: c.err, Count: c.count}
+}
+
+// coalesce records err for addr and reports whether it should be emitted. Errors are
+// identical when their messages are.
+func (m *multiListener) coalesce(addr net.Addr, err error) bool {
+	m.errMut.Lock()
+
+	prev := m.coalesced[addr]
+	if prev != nil && prev.msg == err.Error() {
+		prev.count++
+		m.errMut.Unlock()
+		return false
+	}
+
+	var summary error
+	if prev != nil {
+		prev.timer.Stop()
+		summary = prev.summary()
+	}
+
+	c := &coalescedError{err: err, msg: err.Error()}
+	c.timer = m.opts.clock.AfterFunc(m.opts.coalesce, func() { m.expire(addr, c) })
+	m.coalesced[addr] = c
+
+	m.errMut.Unlock()
+
+	if summary != nil {
+		m.emit(addr, summary)
+	}
+	return true
+}
+
+// expire closes the window of c, reporting its repeats.
+func (m *multiListener) expire(addr net.Addr, c *coalescedError) {
+	m.errMut.Lock()
+	if m.coalesced[addr] != c {
+		m.errMut.Unlock()
+		return
+	}
+	delete(m.coalesced, addr)
+	summary := c.summary()
+	m.errMut.Unlock()
+
+	if summary != nil {
+		m.emit(addr, summary)
+	}
+}
+
+// flushCoalesced closes every window early, reporting their repeats.
+func (m *multiListener) flushCoalesced() {
+	m.errMut.Lock()
+	pending := m.coalesced
+	m.coalesced = map[net.Addr]*coalescedError{}
+	m.errMut.Unlock()
+
+	for addr, c := range pending {
+		c.timer.Stop()
+		if summary := c.summary(); summary != nil {
+			m.emit(addr, summary)
+		}
+	}
+}
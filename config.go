@@ -0,0 +1,123 @@
+package multilistener
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// Config declares a set of listeners with their own settings, for embedding in a larger
+// configuration file.
+type Config struct {
+	Listeners []ListenerSpec `json:"listeners" yaml:"listeners"`
+}
+
+// ListenerSpec declares a single listener.
+type ListenerSpec struct {
+	Network string `json:"network" yaml:"network"`
+	Address string `json:"address" yaml:"address"`
+
+	// TLS serves TLS on the listener, overriding WithTLS.
+	TLS *tls.Config `json:"-" yaml:"-"`
+
+	// ProxyProtocol reads a PROXY protocol header from each conn, like WithProxyProtocol.
+	ProxyProtocol bool `json:"proxy_protocol,omitempty" yaml:"proxy_protocol,omitempty"`
+
+	// MaxConns limits how many conns from the listener may be open at once. Once reached,
+	// new conns wait until one is closed, each socket holding one it accepted and the rest
+	// left in the kernel backlog. The sockets of WithReusePortSockets share the limit. Zero
+	// means no limit.
+	MaxConns int `json:"max_conns,omitempty" yaml:"max_conns,omitempty"`
+}
+
+// ListenConfig listens on every listener in cfg, in order, applying their settings on top of
+// opts. It validates and cleans up like Listen.
+func ListenConfig(cfg Config, opts ...Option) (*MultiListener, error) {
+	m, err := newMultiListener(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := map[string][]string{}
+	pairs := make([]pair, 0, len(cfg.Listeners))
+
+	for _, spec := range cfg.Listeners {
+		listeners[spec.Network] = append(listeners[spec.Network], spec.Address)
+		pairs = append(pairs, pair{network: spec.Network, address: spec.Address})
+	}
+
+	err = checkNetworks(listeners)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.listenPairs(pairs, func(i int, l *managedListener) {
+		spec := cfg.Listeners[i]
+
+		if spec.TLS != nil {
+			l.tlsConfig = spec.TLS
+		}
+		if spec.ProxyProtocol {
+			l.proxy = true
+		}
+		if spec.MaxConns > 0 {
+			l.slots = make(chan struct{}, spec.MaxConns)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m.handle(), nil
+}
+
+// acquire takes a conn slot for l, waiting while l is at its MaxConns. It returns false if l
+// was removed or m was closed meanwhile.
+func (m *multiListener) acquire(l *managedListener) bool {
+	if l.slots == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-m.stop:
+		return false
+	case <-l.quit:
+		return false
+	}
+}
+
+// limitConn gives its slot back when closed.
+type limitConn struct {
+	wrappedConn
+	slots chan struct{}
+	once  sync.Once
+}
+
+// Close closes the conn and frees its slot.
+func (c *limitConn) Close() error {
+	c.once.Do(func() { <-c.slots })
+	return c.Conn.Close()
+}
+
+// ListenGroup listens on every address for a single network with the same opts, for the
+// common case of a uniform set of listeners. Groups with different opts can be served
+// together with Combine, which leaves closing the groups to the caller.
+func ListenGroup(network string, addresses []string, opts ...Option) (*MultiListener, error) {
+	m, err := Listen(map[string][]string{network: addresses}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MultiListener), nil
+}
+
+// ListenWebTLS listens on httpAddr for plain HTTP and on httpsAddr for HTTPS served with
+// tlsConfig, both over tcp, so a single http.Server can Serve both ports with the same
+// handler. Conns from httpsAddr are *tls.Conn, conns from httpAddr are not, unless opts
+// includes WithTLS.
+func ListenWebTLS(httpAddr string, httpsAddr string, tlsConfig *tls.Config, opts ...Option) (*MultiListener, error) {
+	return ListenConfig(Config{Listeners: []ListenerSpec{
+		{Network: "tcp", Address: httpAddr},
+		{Network: "tcp", Address: httpsAddr, TLS: tlsConfig},
+	}}, opts...)
+}
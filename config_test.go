@@ -0,0 +1,253 @@
+package multilistener
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestListenConfig tests that per listener settings from a config are applied.
+func TestListenConfig(t *testing.T) {
+	cert := testCertificate(t)
+
+	m, err := ListenConfig(Config{
+		Listeners: []ListenerSpec{
+			{Network: "tcp", Address: "127.0.0.1:0", TLS: &tls.Config{Certificates: []tls.Certificate{cert}}},
+			{Network: "tcp", Address: "127.0.0.1:0", MaxConns: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	var tlsAddr, limitedAddr string
+	m.mut.RLock()
+	for _, l := range m.listeners {
+		if l.tlsConfig != nil {
+			tlsAddr = l.addr.String()
+		} else {
+			limitedAddr = l.addr.String()
+		}
+	}
+	m.mut.RUnlock()
+
+	client, err := net.Dial("tcp", tlsAddr)
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	if _, ok := c.(*tls.Conn); !ok {
+		t.Error("conn from the TLS listener should be wrapped", c)
+	}
+	c.Close()
+
+	first, err := net.Dial("tcp", limitedAddr)
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer first.Close()
+
+	second, err := net.Dial("tcp", limitedAddr)
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer second.Close()
+
+	c, err = m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+
+	if _, err := m.AcceptTimeout(50 * time.Millisecond); err == nil {
+		t.Error("a second conn should wait while the listener is at MaxConns")
+	}
+
+	c.Close()
+
+	c, err = m.AcceptTimeout(time.Second)
+	if err != nil {
+		t.Fatal("closing a conn should let the next one through", err)
+	}
+	c.Close()
+}
+
+// TestListenConfigReusePortSockets tests that the sockets of one spec share its MaxConns.
+func TestListenConfigReusePortSockets(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("SO_REUSEPORT is not supported on this platform")
+	}
+
+	m, err := ListenConfig(Config{
+		Listeners: []ListenerSpec{
+			{Network: "tcp", Address: "127.0.0.1:0", MaxConns: 1},
+		},
+	}, WithReusePortSockets(4))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	addr := m.Addresses()[0].String()
+	for i := 0; i < 8; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer c.Close()
+	}
+
+	c, err := m.AcceptTimeout(time.Second)
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+
+	if extra, err := m.AcceptTimeout(100 * time.Millisecond); err == nil {
+		extra.Close()
+		t.Error("the sockets of a spec should share its MaxConns")
+	}
+
+	c.Close()
+
+	c, err = m.AcceptTimeout(time.Second)
+	if err != nil {
+		t.Fatal("closing a conn should let the next one through", err)
+	}
+	c.Close()
+}
+
+// TestListenConfigReplaceListener tests that a replaced listener keeps the TLS config of its spec.
+func TestListenConfigReplaceListener(t *testing.T) {
+	cert := testCertificate(t)
+
+	m, err := ListenConfig(Config{
+		Listeners: []ListenerSpec{
+			{Network: "tcp", Address: "127.0.0.1:0", TLS: &tls.Config{Certificates: []tls.Certificate{cert}}},
+		},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	err = m.ReplaceListener(m.Addresses()[0], l)
+	if err != nil {
+		t.Fatal("error when replacing the listener", err)
+	}
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	if _, ok := c.(*tls.Conn); !ok {
+		t.Error("conns from the replacement should still be wrapped in TLS", c)
+	}
+}
+
+// TestListenGroup tests combining groups that were set up with different options.
+func TestListenGroup(t *testing.T) {
+	cert := testCertificate(t)
+
+	secure, err := ListenGroup("tcp", []string{"127.0.0.1:0", "127.0.0.1:0"}, WithTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { secure.Close() })
+
+	plain, err := ListenGroup("tcp", []string{"127.0.0.1:0"})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { plain.Close() })
+
+	if len(secure.Addresses()) != 2 {
+		t.Error("every address of the group should be bound", secure.Addresses())
+	}
+
+	combined := Combine(secure, plain)
+	t.Cleanup(func() { combined.Close() })
+
+	for _, group := range []*MultiListener{secure, plain} {
+		client, err := net.Dial("tcp", group.Addresses()[0].String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer client.Close()
+
+		c, err := combined.Accept()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		defer c.Close()
+
+		if _, isTLS := c.(*tls.Conn); isTLS != (group == secure) {
+			t.Error("conn should keep the options of its group", c)
+		}
+	}
+}
+
+// TestListenWebTLS tests serving plain HTTP and HTTPS from the same http.Server.
+func TestListenWebTLS(t *testing.T) {
+	cert := testCertificate(t)
+
+	m, err := ListenWebTLS("127.0.0.1:0", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strconv.FormatBool(r.TLS != nil))
+	})}
+	go srv.Serve(m)
+	t.Cleanup(func() { srv.Close() })
+
+	var httpAddr, httpsAddr string
+	m.mut.RLock()
+	for _, l := range m.listeners {
+		if l.tlsConfig != nil {
+			httpsAddr = l.addr.String()
+		} else {
+			httpAddr = l.addr.String()
+		}
+	}
+	m.mut.RUnlock()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	defer client.CloseIdleConnections()
+
+	for url, want := range map[string]string{"http://" + httpAddr: "false", "https://" + httpsAddr: "true"} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal("error when requesting", url, err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != want {
+			t.Error("unexpected TLS state for the listener", url, string(body))
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// halfCloser is implemented by conns that can shut down a single direction, such as
+// *net.TCPConn and *net.UnixConn.
+type halfCloser interface {
+	CloseWrite() error
+	CloseRead() error
+}
+
+// wrappedConn is embedded by every conn wrapper the package adds, so that half-close
+// support and unwrapping are never hidden from callers.
+type wrappedConn struct {
+	net.Conn
+}
+
+// CloseWrite shuts down the writing side of the underlying conn when it supports it.
+func (c *wrappedConn) CloseWrite() error {
+	if hc, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return &net.OpError{Op: "close", Net: c.LocalAddr().Network(), Addr: c.LocalAddr(), Err: errors.ErrUnsupported}
+}
+
+// CloseRead shuts down the reading side of the underlying conn when it supports it.
+func (c *wrappedConn) CloseRead() error {
+	if hc, ok := c.Conn.(interface{ CloseRead() error }); ok {
+		return hc.CloseRead()
+	}
+	return &net.OpError{Op: "close", Net: c.LocalAddr().Network(), Addr: c.LocalAddr(), Err: errors.ErrUnsupported}
+}
+
+// NetConn returns the conn that is wrapped.
+func (c *wrappedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// netConner is implemented by conns that wrap another conn, like *tls.Conn.
+type netConner interface {
+	NetConn() net.Conn
+}
+
+// contextConn carries the context created for a conn by WithConnContext.
+type contextConn struct {
+	wrappedConn
+	ctx context.Context
+}
+
+// Context returns the context created for the conn when it was accepted.
+func (c *contextConn) Context() context.Context {
+	return c.ctx
+}
+
+// ConnContext returns the context WithConnContext created for c, looking through any
+// wrappers like *tls.Conn. It returns context.Background if c has none.
+func ConnContext(c net.Conn) context.Context {
+	for c != nil {
+		if cc, ok := c.(interface{ Context() context.Context }); ok {
+			return cc.Context()
+		}
+
+		nc, ok := c.(netConner)
+		if !ok {
+			break
+		}
+		c = nc.NetConn()
+	}
+
+	return context.Background()
+}
+
+// firstByteConn reports how long after accept the first byte was read.
+type firstByteConn struct {
+	wrappedConn
+	addr     net.Addr
+	accepted time.Time
+	clock    clock
+	observe  func(net.Addr, time.Duration)
+	read     atomic.Bool
+}
+
+// Read implements net.Conn.
+func (c *firstByteConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && !c.read.Swap(true) {
+		c.observe(c.addr, c.clock.Now().Sub(c.accepted))
+	}
+	return n, err
+}
+
+// trackedConn removes itself from the multilistener's live conns when closed.
+type trackedConn struct {
+	wrappedConn
+	m    *multiListener
+	once sync.Once
+}
+
+// Close closes the conn and stops tracking it.
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.m.connMut.Lock()
+		delete(c.m.conns, c)
+		c.m.connMut.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// track starts tracking c as a live conn.
+func (m *multiListener) track(c net.Conn) net.Conn {
+	t := &trackedConn{wrappedConn: wrappedConn{Conn: c}, m: m}
+
+	m.connMut.Lock()
+	m.conns[t] = struct{}{}
+	m.connMut.Unlock()
+
+	return t
+}
+
+// closeConns closes every tracked conn that is still open and returns how many it closed.
+func (m *multiListener) closeConns() int {
+	m.connMut.Lock()
+	conns := make([]*trackedConn, 0, len(m.conns))
+	for c := range m.conns {
+		conns = append(conns, c)
+	}
+	m.connMut.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+	return len(conns)
+}
+
+// OpenConns returns how many accepted conns are still open, so a deploy script can poll it
+// after Drain or Shutdown until it reaches zero. Only conns tracked with WithConnTracking,
+// WithCloseConnsOnShutdown or WithForceCloseOnShutdownTimeout are counted; it is always 0
+// otherwise.
+func (m *multiListener) OpenConns() int {
+	m.connMut.Lock()
+	defer m.connMut.Unlock()
+
+	return len(m.conns)
+}
+
+var _ halfCloser = &wrappedConn{}
@@ -0,0 +1,369 @@
+package multilistener
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWrappedConnHalfClose tests that half-close is forwarded through a wrapped conn.
+func TestWrappedConnHalfClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error("error accepting", err)
+			return
+		}
+		defer c.Close()
+
+		var w halfCloser = &wrappedConn{Conn: c}
+
+		_, err = c.Write([]byte("ping"))
+		if err != nil {
+			t.Error("error writing", err)
+		}
+
+		err = w.CloseWrite()
+		if err != nil {
+			t.Error("close write should be forwarded", err)
+		}
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("error dialing", err)
+	}
+	defer c.Close()
+
+	b, err := io.ReadAll(c)
+	if err != nil || string(b) != "ping" {
+		t.Error("half-closed conn should deliver data and then EOF", string(b), err)
+	}
+
+	p, _ := net.Pipe()
+	defer p.Close()
+
+	err = (&wrappedConn{Conn: p}).CloseWrite()
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Error("conns without half-close should report unsupported", err)
+	}
+}
+
+type connContextKey struct{}
+
+// TestWithConnContext tests retrieving the context seeded at accept, including through TLS.
+func TestWithConnContext(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithTLS(&tls.Config{}), WithConnContext(func(ctx context.Context, addr net.Addr) context.Context {
+		return context.WithValue(ctx, connContextKey{}, addr.String())
+	}))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	go func() {
+		c, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Error("error connecting to listener", err)
+			return
+		}
+		c.Close()
+	}()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error accepting from listener", err)
+	}
+	defer c.Close()
+
+	if _, ok := c.(*tls.Conn); !ok {
+		t.Error("tls listeners should still yield tls conns", c)
+	}
+
+	if v := ConnContext(c).Value(connContextKey{}); v != m.Addr().String() {
+		t.Error("conn context should be derived from the listener address", v)
+	}
+
+	if ConnContext(&net.TCPConn{}) != context.Background() {
+		t.Error("conns without a context should return the background context")
+	}
+}
+
+// TestWithCloseConnsOnShutdown tests closing live conns on Close in both modes.
+func TestWithCloseConnsOnShutdown(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		m, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0"},
+		}, WithCloseConnsOnShutdown(enabled))
+
+		if err != nil {
+			t.Fatal("error when listening on valid addresses", err)
+		}
+
+		client, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error connecting to listener", err)
+		}
+		defer client.Close()
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error accepting from listener", err)
+		}
+		defer c.Close()
+
+		m.Close()
+
+		_, err = c.Write([]byte("ping"))
+		if enabled && err == nil {
+			t.Error("live conns should be closed on shutdown")
+		}
+		if !enabled && err != nil {
+			t.Error("live conns should be left open", err)
+		}
+	}
+}
+
+// TestWithConnPipeline tests that stages run in order and failing conns are closed and skipped.
+func TestWithConnPipeline(t *testing.T) {
+	stageErr := errors.New("rejected")
+	order := make(chan string, 4)
+	first := true
+
+	errs := make(chan error, 1)
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithErrorHook(func(addr net.Addr, err error) {
+		errs <- err
+	}), WithConnPipeline([]func(net.Conn) (net.Conn, error){
+		func(c net.Conn) (net.Conn, error) {
+			order <- "first"
+			return c, nil
+		},
+		func(c net.Conn) (net.Conn, error) {
+			order <- "second"
+			if first {
+				first = false
+				return nil, stageErr
+			}
+			return c, nil
+		},
+	}))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	rejected, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error connecting to listener", err)
+	}
+	defer rejected.Close()
+
+	if err := <-errs; !errors.Is(err, stageErr) {
+		t.Error("stage errors should be reported", err)
+	}
+
+	_, err = rejected.Read(make([]byte, 1))
+	if err == nil {
+		t.Error("conns failing a stage should be closed")
+	}
+
+	accepted, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error connecting to listener", err)
+	}
+	defer accepted.Close()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error accepting from listener", err)
+	}
+	c.Close()
+
+	for _, expected := range []string{"first", "second", "first", "second"} {
+		if s := <-order; s != expected {
+			t.Error("stages should run in order", s, expected)
+		}
+	}
+}
+
+// TestWithFirstByteObserver tests that the first read is observed once per conn.
+func TestWithFirstByteObserver(t *testing.T) {
+	observed := make(chan net.Addr, 2)
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithFirstByteObserver(func(addr net.Addr, d time.Duration) {
+		if d < 50*time.Millisecond {
+			t.Error("first byte should be measured from accept", d)
+		}
+		observed <- addr
+	}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	client, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	client.Write([]byte("hello"))
+
+	buf := make([]byte, 1)
+	for i := 0; i < 5; i++ {
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatal("error when reading", err)
+		}
+	}
+
+	if addr := <-observed; addr.String() != m.Addr().String() {
+		t.Error("observer should get the listener address", addr)
+	}
+
+	if len(observed) != 0 {
+		t.Error("only the first read should be observed")
+	}
+}
+
+// TestWithConnTap tests that reads from every conn are copied to the tap.
+func TestWithConnTap(t *testing.T) {
+	var buf bytes.Buffer
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithConnTap(&buf))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	clients := []net.Conn{}
+	var wg sync.WaitGroup
+
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer client.Close()
+		clients = append(clients, client)
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		defer c.Close()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.ReadAll(c)
+		}()
+	}
+
+	for i, client := range clients {
+		client.Write([]byte("hello " + strconv.Itoa(i)))
+		client.Close()
+	}
+	wg.Wait()
+
+	for i, client := range clients {
+		line := client.LocalAddr().String() + ": hello " + strconv.Itoa(i) + "\n"
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("tap is missing %q in %q", line, buf.String())
+		}
+	}
+}
+
+// TestWithAcceptFilter tests that vetoed conns are closed and counted without being reported.
+func TestWithAcceptFilter(t *testing.T) {
+	reported := make(chan error, 1)
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithAcceptFilter(func(c net.Conn) (net.Conn, error) {
+		token := make([]byte, 2)
+		if _, err := io.ReadFull(c, token); err != nil {
+			return nil, err
+		}
+		if string(token) != "ok" {
+			return nil, errors.New("bad token")
+		}
+		return c, nil
+	}), WithErrorHook(func(addr net.Addr, err error) {
+		reported <- err
+	}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	ml := m.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	for _, token := range []string{"no", "ok"} {
+		client, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer client.Close()
+
+		client.Write([]byte(token + "!"))
+	}
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	rest := make([]byte, 1)
+	if _, err := io.ReadFull(c, rest); err != nil || string(rest) != "!" {
+		t.Error("the accepted conn should be the one with a valid token", err, string(rest))
+	}
+
+	if n := ml.Rejected(); n != 1 {
+		t.Error("one conn should be rejected", n)
+	}
+
+	if len(reported) != 0 {
+		t.Error("rejections should not be reported", <-reported)
+	}
+}
@@ -0,0 +1,62 @@
+package multilistener
+
+import (
+	"sync"
+	"time"
+)
+
+// deadline is a deadline that can be moved while something waits on it, like the read
+// deadline of a net.Conn.
+type deadline struct {
+	mut    *sync.Mutex
+	clock  clock
+	timer  clockTimer
+	passed chan struct{}
+}
+
+// newDeadline creates a deadline that is not set.
+func newDeadline(c clock) *deadline {
+	return &deadline{mut: &sync.Mutex{}, clock: c, passed: make(chan struct{})}
+}
+
+// set moves the deadline to t. The zero time means no deadline, a time in the past means it
+// has already passed.
+func (d *deadline) set(t time.Time) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	// A timer that already fired may still be about to close passed.
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.passed
+	}
+	d.timer = nil
+
+	select {
+	case <-d.passed:
+		d.passed = make(chan struct{})
+	default:
+	}
+
+	if t.IsZero() {
+		return
+	}
+
+	wait := t.Sub(d.clock.Now())
+	if wait <= 0 {
+		close(d.passed)
+		return
+	}
+
+	passed := d.passed
+	d.timer = d.clock.AfterFunc(wait, func() {
+		close(passed)
+	})
+}
+
+// done returns a channel that is closed once the deadline passed.
+func (d *deadline) done() <-chan struct{} {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	return d.passed
+}
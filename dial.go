@@ -0,0 +1,82 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+)
+
+// ErrNoDialableAddr is returned by DialAny when no bound address can be dialed.
+var ErrNoDialableAddr = errors.New("no dialable address")
+
+// DialAny dials one of the bound addresses and returns the client side of the conn, which
+// is handy in tests that do not care which listener they reach. Loopback addresses are tried
+// first, then wildcard ones through loopback, then unix sockets and finally the rest. Only
+// TCP and unix listeners are dialed. The conn is accepted like any other.
+func (m *multiListener) DialAny(ctx context.Context) (net.Conn, error) {
+	targets := dialTargets(m.Addresses())
+	if len(targets) == 0 {
+		return nil, ErrNoDialableAddr
+	}
+
+	var d net.Dialer
+	errs := []error{}
+
+	for _, target := range targets {
+		c, err := d.DialContext(ctx, target.Network(), target.String())
+		if err == nil {
+			return c, nil
+		}
+
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// dialTargets orders addrs by preference for DialAny, pointing wildcard TCP addresses at
+// loopback and leaving out addresses that can not be dialed.
+func dialTargets(addrs []net.Addr) []net.Addr {
+	type target struct {
+		addr net.Addr
+		rank int
+	}
+
+	targets := []target{}
+
+	for _, addr := range addrs {
+		switch a := addr.(type) {
+		case *net.TCPAddr:
+			switch {
+			case a.IP.IsLoopback():
+				targets = append(targets, target{a, 0})
+			case a.IP == nil || a.IP.IsUnspecified():
+				loopback := net.IPv6loopback
+				if a.IP == nil || a.IP.To4() != nil {
+					loopback = net.IPv4(127, 0, 0, 1)
+				}
+				targets = append(targets, target{&net.TCPAddr{IP: loopback, Port: a.Port}, 1})
+			default:
+				targets = append(targets, target{a, 3})
+			}
+		case *net.UnixAddr:
+			if a.Net != "unixgram" {
+				targets = append(targets, target{a, 2})
+			}
+		}
+	}
+
+	slices.SortStableFunc(targets, func(a, b target) int {
+		return a.rank - b.rank
+	})
+
+	out := make([]net.Addr, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, t.addr)
+	}
+	return out
+}
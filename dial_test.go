@@ -0,0 +1,71 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// TestDialTargets tests the order DialAny tries addresses in.
+func TestDialTargets(t *testing.T) {
+	targets := dialTargets([]net.Addr{
+		&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1},
+		&net.UnixAddr{Name: "/tmp/a.sock", Net: "unix"},
+		&net.TCPAddr{IP: net.IPv4zero, Port: 2},
+		&net.TCPAddr{IP: net.IPv6unspecified, Port: 3},
+		&net.TCPAddr{IP: net.IPv6loopback, Port: 4},
+		&net.UnixAddr{Name: "/tmp/b.sock", Net: "unixgram"},
+	})
+
+	expected := []string{"[::1]:4", "127.0.0.1:2", "[::1]:3", "/tmp/a.sock", "192.0.2.1:1"}
+
+	if len(targets) != len(expected) {
+		t.Fatal("unexpected targets", targets)
+	}
+
+	for i, target := range targets {
+		if target.String() != expected[i] {
+			t.Error("unexpected target", i, target, expected[i])
+		}
+	}
+}
+
+// TestDialAny tests that DialAny reaches the multilistener.
+func TestDialAny(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"unix": {filepath.Join(t.TempDir(), "dial.sock")},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	client, err := m.(*MultiListener).DialAny(context.Background())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	if client.RemoteAddr().Network() != "tcp" {
+		t.Error("loopback tcp should be preferred", client.RemoteAddr())
+	}
+
+	empty, err := New(nil)
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	defer empty.Close()
+
+	if _, err := empty.DialAny(context.Background()); !errors.Is(err, ErrNoDialableAddr) {
+		t.Error("nothing to dial should return ErrNoDialableAddr", err)
+	}
+}
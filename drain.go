@@ -0,0 +1,44 @@
+package multilistener
+
+import "net"
+
+// WithDrainResponder sets what Drain does with new conns: responder is called with each one,
+// in its own goroutine, and the conn is closed once it returns. It can write a short goodbye,
+// such as an HTTP 503. Without a responder drained conns are closed right away.
+func WithDrainResponder(responder func(net.Conn)) Option {
+	return func(o *options) {
+		o.drainResponder = responder
+	}
+}
+
+// Drain keeps every socket bound but stops handing out new conns; instead each one is passed
+// to the WithDrainResponder responder and closed. Resume ends draining, as does Close.
+// Conns already handed out are unaffected.
+func (m *multiListener) Drain() {
+	m.draining.Store(true)
+}
+
+// Draining reports whether the multilistener is draining.
+func (m *multiListener) Draining() bool {
+	return m.draining.Load()
+}
+
+// Drained returns how many conns were turned away while draining.
+func (m *multiListener) Drained() uint64 {
+	return m.drained.Load()
+}
+
+// respond turns c away while draining.
+func (m *multiListener) respond(c net.Conn) {
+	m.drained.Add(1)
+
+	if m.opts.drainResponder == nil {
+		c.Close()
+		return
+	}
+
+	m.goRoutine(func() {
+		defer c.Close()
+		m.opts.drainResponder(c)
+	})
+}
@@ -0,0 +1,22 @@
+//go:build !windows && !plan9
+
+package multilistener
+
+import "syscall"
+
+var bindErrnos = []bindErrno{
+	{syscall.EADDRINUSE, ErrAddrInUse, false},
+	{syscall.EACCES, ErrPermissionDenied, false},
+	{syscall.EADDRNOTAVAIL, ErrAddrNotAvailable, false},
+	{syscall.ENOENT, ErrSocketDirNotFound, true},
+}
+
+// fdErrnos are the accept errnos of a process or system out of file descriptors.
+var fdErrnos = []error{syscall.EMFILE, syscall.ENFILE}
+
+// temporaryErrnos are the accept errnos, besides those syscall.Errno considers temporary, of a
+// conn that went away before it was accepted or of a system short on memory for a moment.
+var temporaryErrnos = []error{syscall.ECONNABORTED, syscall.ECONNRESET, syscall.ENOBUFS, syscall.ENOMEM}
+
+// staleErrnos are the dial errnos of a unix socket file nothing listens on.
+var staleErrnos = []error{syscall.ECONNREFUSED}
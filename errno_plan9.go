@@ -0,0 +1,13 @@
+package multilistener
+
+// Plan 9 reports bind failures as strings, so none are classified.
+var bindErrnos = []bindErrno{}
+
+// fdErrnos is empty, as Plan 9 reports fd exhaustion as a string too.
+var fdErrnos = []error{}
+
+// temporaryErrnos is empty, as Plan 9 reports accept errors as strings.
+var temporaryErrnos = []error{}
+
+// staleErrnos is empty, as Plan 9 has no unix sockets.
+var staleErrnos = []error{}
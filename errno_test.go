@@ -0,0 +1,221 @@
+//go:build !plan9
+
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestErrAddrInUse tests that binding a used address maps onto ErrAddrInUse.
+func TestErrAddrInUse(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	address := m.Addr().String()
+
+	_, err = Listen(map[string][]string{
+		"tcp": {address},
+	})
+
+	var listenErr *ListenError
+	if !errors.As(err, &listenErr) || listenErr.Address != address {
+		t.Error("error should be a listen error for the used address", err)
+	}
+
+	if !errors.Is(err, ErrAddrInUse) {
+		t.Error("error should match ErrAddrInUse", err)
+	}
+
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		t.Error("error should still unwrap to the platform errno", err)
+	}
+}
+
+// TestBindErrorHints tests that common bind failures are classified and explained.
+func TestBindErrorHints(t *testing.T) {
+	cases := []struct {
+		network  string
+		address  string
+		sentinel error
+		hint     string
+	}{
+		{"tcp", "192.0.2.1:0", ErrAddrNotAvailable, "no local interface"},
+		{"unix", filepath.Join(t.TempDir(), "missing", "hint.sock"), ErrSocketDirNotFound, "directory"},
+	}
+
+	if os.Getuid() > 0 {
+		cases = append(cases, struct {
+			network  string
+			address  string
+			sentinel error
+			hint     string
+		}{"tcp", "127.0.0.1:1", ErrPermissionDenied, "privileges"})
+	}
+
+	for _, c := range cases {
+		_, err := Listen(map[string][]string{
+			c.network: {c.address},
+		})
+
+		if !errors.Is(err, c.sentinel) {
+			t.Error("unexpected bind error", c.address, err)
+			continue
+		}
+
+		var listenErr *ListenError
+		if !errors.As(err, &listenErr) {
+			t.Error("error should be a listen error", err)
+		}
+
+		if !strings.Contains(err.Error(), c.hint) {
+			t.Error("error should carry a hint", err)
+		}
+	}
+}
+
+// TestWithFDExhaustionCooldown tests that running out of file descriptors pauses every listener.
+func TestWithFDExhaustionCooldown(t *testing.T) {
+	clock := newFakeClock()
+	reported := make(chan error, 10)
+
+	// The healthy listener is kept in its accept until the other one ran out.
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	var failed atomic.Bool
+	exhausted := newFakeListener(func() (net.Conn, error) {
+		if !failed.Swap(true) {
+			<-entered
+			return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+		}
+		c, s := net.Pipe()
+		c.Close()
+		return s, nil
+	})
+
+	var once sync.Once
+	healthy := newFakeListener(func() (net.Conn, error) {
+		once.Do(func() { close(entered) })
+		<-release
+		c, s := net.Pipe()
+		c.Close()
+		return s, nil
+	})
+
+	m, err := New([]net.Listener{exhausted, healthy}, withClock(clock), WithFDExhaustionCooldown(time.Minute), WithErrorHook(func(addr net.Addr, err error) {
+		reported <- err
+	}))
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	if err := <-reported; !errors.Is(err, ErrFDExhausted) || !errors.Is(err, syscall.EMFILE) {
+		t.Error("fd exhaustion should be reported", err)
+	}
+
+	// The healthy listener finishes its accept, then cools down as well.
+	close(release)
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("the accept error should not be returned from accept", err)
+	}
+	conn.Close()
+
+	clock.awaitTimers(t, 2)
+
+	accepted := make(chan error, 1)
+	go func() {
+		conn, err := m.Accept()
+		if conn != nil {
+			conn.Close()
+		}
+		accepted <- err
+	}()
+
+	select {
+	case err := <-accepted:
+		t.Fatal("listeners should not accept while cooling down", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+
+	select {
+	case err := <-accepted:
+		if err != nil {
+			t.Error("listeners should accept after the cooldown", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listeners did not resume after the cooldown")
+	}
+
+	if len(reported) != 0 {
+		t.Error("fd exhaustion should be reported once", <-reported)
+	}
+}
+
+// TestWithAcceptBackoff tests that temporary accept errors are retried with growing delays
+// instead of being returned, while other errors still are.
+func TestWithAcceptBackoff(t *testing.T) {
+	clock := newFakeClock()
+	reported := make(chan error, 10)
+	boom := errors.New("boom")
+
+	var calls atomic.Int32
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		switch calls.Add(1) {
+		case 1, 2, 3:
+			return nil, &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.ECONNABORTED)}
+		case 4:
+			return nil, boom
+		}
+		c, s := net.Pipe()
+		c.Close()
+		return s, nil
+	})}, withClock(clock), WithAcceptBackoff(8*time.Millisecond), WithErrorHook(func(addr net.Addr, err error) {
+		reported <- err
+	}))
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	for _, d := range []time.Duration{5 * time.Millisecond, 8 * time.Millisecond, 8 * time.Millisecond} {
+		if err := <-reported; !errors.Is(err, syscall.ECONNABORTED) || !strings.Contains(err.Error(), d.String()) {
+			t.Fatal("temporary errors should be reported with the delay", d, err)
+		}
+
+		clock.awaitTimers(t, 1)
+		clock.Advance(d)
+	}
+
+	if _, err := m.Accept(); err != boom {
+		t.Error("other errors should be returned from accept", err)
+	}
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("accept should succeed after the temporary errors", err)
+	}
+	conn.Close()
+}
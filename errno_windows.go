@@ -0,0 +1,34 @@
+package multilistener
+
+import "syscall"
+
+// Winsock errnos, which the syscall package does not define.
+const (
+	wsaeacces        syscall.Errno = 10013
+	wsaeaddrinuse    syscall.Errno = 10048
+	wsaeaddrnotavail syscall.Errno = 10049
+	wsaemfile        syscall.Errno = 10024
+	wsaeconnrefused  syscall.Errno = 10061
+	wsaeconnaborted  syscall.Errno = 10053
+	wsaeconnreset    syscall.Errno = 10054
+	wsaenobufs       syscall.Errno = 10055
+)
+
+var bindErrnos = []bindErrno{
+	{syscall.EADDRINUSE, ErrAddrInUse, false},
+	{wsaeaddrinuse, ErrAddrInUse, false},
+	{syscall.EACCES, ErrPermissionDenied, false},
+	{wsaeacces, ErrPermissionDenied, false},
+	{wsaeaddrnotavail, ErrAddrNotAvailable, false},
+	{syscall.ERROR_PATH_NOT_FOUND, ErrSocketDirNotFound, true},
+}
+
+// fdErrnos are the accept errnos of a process out of sockets.
+var fdErrnos = []error{wsaemfile, syscall.EMFILE}
+
+// temporaryErrnos are the accept errnos of a conn that went away before it was accepted or
+// of a system short on buffers for a moment.
+var temporaryErrnos = []error{wsaeconnaborted, wsaeconnreset, wsaenobufs}
+
+// staleErrnos are the dial errnos of a unix socket file nothing listens on.
+var staleErrnos = []error{wsaeconnrefused, syscall.ECONNREFUSED}
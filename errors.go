@@ -0,0 +1,194 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrUnknownNetwork is returned when a network is not one the package can listen on.
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// ErrInvalidAddress is returned when an address does not have the right shape for its network.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ErrAddrInUse is returned when an address is already bound, regardless of platform.
+var ErrAddrInUse = errors.New("address already in use")
+
+// ErrPermissionDenied is returned when binding is not permitted, such as for privileged
+// ports or unix sockets in directories that are not writable.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrAddrNotAvailable is returned when binding an address that no local interface has.
+var ErrAddrNotAvailable = errors.New("address not available")
+
+// ErrSocketDirNotFound is returned when the directory of a unix socket path does not exist.
+var ErrSocketDirNotFound = errors.New("socket directory does not exist")
+
+// ErrTooFewListeners is returned by Listen when fewer listeners bound than WithMinListeners requires.
+var ErrTooFewListeners = errors.New("too few listeners bound")
+
+// errorsBuffer is how many errors the Errors channel holds before dropping them.
+const errorsBuffer = 64
+
+// ListenerError is an error observed in the background along with the address of the
+// listener it came from.
+type ListenerError struct {
+	Addr net.Addr
+	Err  error
+}
+
+// Error implements error.
+func (e ListenerError) Error() string {
+	return e.Addr.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e ListenerError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns a channel of errors observed in the background, as an alternative to
+// WithErrorHook. The channel is buffered and errors are dropped rather than blocking the
+// accept routines when it is full; DroppedErrors counts them. Errors are only sent once
+// Errors has been called. The channel is closed when the multilistener is closed.
+func (m *multiListener) Errors() <-chan ListenerError {
+	m.errMut.Lock()
+	defer m.errMut.Unlock()
+
+	if m.errs == nil {
+		m.errs = make(chan ListenerError, errorsBuffer)
+		if m.errsClosed {
+			close(m.errs)
+		}
+	}
+
+	return m.errs
+}
+
+// DroppedErrors returns how many errors were dropped because the Errors channel was full.
+func (m *multiListener) DroppedErrors() uint64 {
+	return m.dropped.Load()
+}
+
+// closeErrors closes the errors channel so consumers stop ranging over it.
+func (m *multiListener) closeErrors() {
+	m.errMut.Lock()
+	defer m.errMut.Unlock()
+
+	if m.errs != nil && !m.errsClosed {
+		close(m.errs)
+	}
+	m.errsClosed = true
+}
+
+// bindErrno maps a platform errno returned by a bind onto a package sentinel. Entries
+// marked unix only apply to unix socket networks.
+type bindErrno struct {
+	errno    error
+	sentinel error
+	unix     bool
+}
+
+// ListenError describes a failure to listen on a single network/address pair.
+// It unwraps to both the underlying error and, for well known bind failures,
+// a package sentinel like ErrAddrInUse.
+type ListenError struct {
+	Network string
+	Address string
+	Err     error
+
+	reason error
+}
+
+// newListenError wraps a bind error and classifies it.
+func newListenError(network string, address string, err error) *ListenError {
+	e := &ListenError{Network: network, Address: address, Err: err}
+
+	unix := strings.HasPrefix(network, "unix")
+
+	for _, b := range bindErrnos {
+		if b.unix && !unix {
+			continue
+		}
+		if errors.Is(err, b.errno) {
+			e.reason = b.sentinel
+			break
+		}
+	}
+
+	return e
+}
+
+// Error implements error.
+func (e *ListenError) Error() string {
+	msg := e.Err.Error()
+
+	// net errors repeat the op, network and address, so only keep their cause.
+	var opErr *net.OpError
+	if errors.As(e.Err, &opErr) && opErr.Err != nil {
+		msg = opErr.Err.Error()
+	}
+
+	if hint := e.hint(); hint != "" {
+		msg += " (" + hint + ")"
+	}
+
+	if e.Address == "" {
+		return "listen " + e.Network + ": " + msg
+	}
+	return "listen " + e.Network + " " + e.Address + ": " + msg
+}
+
+// hint suggests how to fix a classified bind failure.
+func (e *ListenError) hint() string {
+	unix := strings.HasPrefix(e.Network, "unix")
+
+	switch {
+	case e.reason == ErrPermissionDenied && unix:
+		return "the socket's directory must be writable"
+	case e.reason == ErrPermissionDenied:
+		return "ports below 1024 usually need elevated privileges, such as CAP_NET_BIND_SERVICE on linux"
+	case e.reason == ErrAddrNotAvailable:
+		return "no local interface has this address"
+	case e.reason == ErrSocketDirNotFound:
+		return "create the socket's directory first"
+	}
+	return ""
+}
+
+// Unwrap returns the underlying error and the sentinel it was classified as.
+func (e *ListenError) Unwrap() []error {
+	if e.reason == nil {
+		return []error{e.Err}
+	}
+	return []error{e.reason, e.Err}
+}
+
+// MinListenersError is returned by Listen when fewer than Min of its listeners bound.
+// It unwraps to ErrTooFewListeners and to each bind failure.
+type MinListenersError struct {
+	Bound  int
+	Min    int
+	Failed []error
+}
+
+// Error implements error.
+func (e *MinListenersError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		msgs = append(msgs, err.Error())
+	}
+
+	msg := fmt.Sprintf("%s: %d bound, need %d", ErrTooFewListeners, e.Bound, e.Min)
+	if len(msgs) == 0 {
+		return msg
+	}
+	return msg + ": " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns ErrTooFewListeners and the bind failures.
+func (e *MinListenersError) Unwrap() []error {
+	return append([]error{ErrTooFewListeners}, e.Failed...)
+}
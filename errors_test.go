@@ -0,0 +1,130 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestErrClosed tests that ErrClosed interoperates with net.ErrClosed.
+func TestErrClosed(t *testing.T) {
+	if !errors.Is(ErrClosed, net.ErrClosed) {
+		t.Error("ErrClosed should match net.ErrClosed")
+	}
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	m.Close()
+
+	_, err = m.Accept()
+	if !errors.Is(err, net.ErrClosed) {
+		t.Error("accepting from a closed listener should match net.ErrClosed", err)
+	}
+}
+
+// TestErrClosedDuringAccept tests that closing during a blocked Accept always presents
+// as ErrClosed, never as the error of a listener unblocked by the close.
+func TestErrClosedDuringAccept(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		m, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0", "127.0.0.1:0"},
+		}, WithAcceptBuffer(4))
+
+		if err != nil {
+			t.Fatal("error when listening on valid addresses", err)
+		}
+
+		accepted := make(chan error, 1)
+		go func() {
+			_, err := m.Accept()
+			accepted <- err
+		}()
+
+		time.Sleep(time.Millisecond)
+		m.Close()
+
+		if err := <-accepted; err != ErrClosed {
+			t.Fatal("a blocked accept should return ErrClosed on close", err)
+		}
+	}
+}
+
+// TestErrors tests receiving background errors over a channel.
+func TestErrors(t *testing.T) {
+	acceptErr := errors.New("accept failed")
+
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		time.Sleep(time.Millisecond)
+		return nil, acceptErr
+	})})
+
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	errs := m.Errors()
+
+	select {
+	case e := <-errs:
+		if !errors.Is(e, acceptErr) || e.Addr.String() != "127.0.0.1:1" {
+			t.Error("errors should carry the accept error and its listener", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("accept errors should be sent on the channel")
+	}
+
+	m.Close()
+
+	for range errs {
+	}
+}
+
+// TestWithErrorCoalescing tests that repeats of an error are folded into one summary.
+func TestWithErrorCoalescing(t *testing.T) {
+	clock := newFakeClock()
+	reported := make(chan error, 10)
+	boom := errors.New("boom")
+
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		return nil, boom
+	})}, withClock(clock), WithErrorCoalescing(time.Minute), WithErrorHook(func(addr net.Addr, err error) {
+		reported <- err
+	}))
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	for i := 0; i < 5; i++ {
+		if _, err := m.Accept(); !errors.Is(err, boom) {
+			t.Fatal("unexpected accept error", err)
+		}
+	}
+
+	if err := <-reported; err != boom {
+		t.Error("the first error should be reported as is", err)
+	}
+
+	if len(reported) != 0 {
+		t.Error("repeats should be suppressed", <-reported)
+	}
+
+	clock.Advance(time.Minute)
+
+	var repeated *RepeatedError
+	select {
+	case err := <-reported:
+		if !errors.As(err, &repeated) || !errors.Is(err, boom) || repeated.Count < 4 {
+			t.Error("repeats should be summarized when the window closes", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no summary was reported")
+	}
+}
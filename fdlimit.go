@@ -0,0 +1,70 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrFDExhausted is reported when WithFDExhaustionCooldown pauses accepting because the
+// process or system ran out of file descriptors.
+var ErrFDExhausted = errors.New("out of file descriptors")
+
+// WithFDExhaustionCooldown pauses the accept routines of every listener for d once any of them
+// fails with EMFILE or ENFILE, instead of each one spinning on accept errors while the process
+// is out of file descriptors. The failure is reported once per cooldown through the error
+// hook as ErrFDExhausted, and not returned from Accept. Accepting resumes after d, and a
+// failure then starts another cooldown.
+func WithFDExhaustionCooldown(d time.Duration) Option {
+	return func(o *options) {
+		o.fdCooldown = d
+	}
+}
+
+// exhausted reports whether the accept error err of l means file descriptors ran out and
+// starts a cooldown if so.
+// The first failure of a cooldown is reported.
+func (m *multiListener) exhausted(l *managedListener, err error) bool {
+	if m.opts.fdCooldown <= 0 || !isFDExhausted(err) {
+		return false
+	}
+
+	now := m.opts.clock.Now().UnixNano()
+	until := m.cooldown.Load()
+	if until <= now && m.cooldown.CompareAndSwap(until, now+int64(m.opts.fdCooldown)) {
+		m.report(l.addr, fmt.Errorf("%w, pausing accepts for %s: %w", ErrFDExhausted, m.opts.fdCooldown, err))
+	}
+	return true
+}
+
+// coolDown waits for a cooldown started by exhausted to pass. It returns false if the
+// multilistener is closed or l removed meanwhile.
+func (m *multiListener) coolDown(l *managedListener) bool {
+	until := m.cooldown.Load()
+	d := time.Duration(until - m.opts.clock.Now().UnixNano())
+	if until == 0 || d <= 0 {
+		return true
+	}
+
+	timer := m.opts.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-m.stop:
+		return false
+	case <-l.quit:
+		return false
+	}
+}
+
+// isFDExhausted reports whether err is one of fdErrnos.
+func isFDExhausted(err error) bool {
+	for _, errno := range fdErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+)
+
+// errRejected is returned by wrap for conns vetoed by the accept filter.
+var errRejected = errors.New("conn rejected by accept filter")
+
+// WithAcceptFilter runs filter on every accepted conn before it is handed out. Unlike
+// address based checks it gets the live conn, so it can read the first bytes or negotiate
+// a protocol, and return the conn to hand out, possibly wrapped. If filter returns an error
+// the conn is closed, counted by Rejected and skipped without being reported.
+//
+// filter runs after every other conn stage, in the listener's accept routine, so a slow
+// filter holds up that listener; use WithAcceptWorkers or set a deadline on the conn.
+func WithAcceptFilter(filter func(net.Conn) (net.Conn, error)) Option {
+	return func(o *options) {
+		o.acceptFilter = filter
+	}
+}
+
+// Rejected returns how many conns WithAcceptFilter has rejected.
+func (m *multiListener) Rejected() uint64 {
+	return m.rejected.Load()
+}
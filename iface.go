@@ -0,0 +1,80 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ErrNoInterfaceAddrs is returned by ListenInterface when the interface has no usable addresses.
+var ErrNoInterfaceAddrs = errors.New("interface has no usable addresses")
+
+// WithoutLinkLocal makes ListenInterface skip link-local addresses in 169.254.0.0/16 and fe80::/10.
+func WithoutLinkLocal() Option {
+	return func(o *options) {
+		o.noLinkLocal = true
+	}
+}
+
+// ListenInterface listens on port on every address the named interface has when it is called,
+// using tcp4 for IPv4 and tcp6 for IPv6 addresses. Link-local IPv6 addresses are bound with
+// the interface as their zone. Addresses added to the interface later are not picked up.
+func ListenInterface(name string, port int, opts ...Option) (*MultiListener, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := interfaceListeners(iface.Name, addrs, port, newOptions(opts...).noLinkLocal)
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoInterfaceAddrs, name)
+	}
+
+	m, err := Listen(listeners, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MultiListener), nil
+}
+
+// interfaceListeners builds the Listen map for the addresses of the interface called name.
+func interfaceListeners(name string, addrs []net.Addr, port int, noLinkLocal bool) map[string][]string {
+	listeners := map[string][]string{}
+
+	for _, addr := range addrs {
+		var ip net.IP
+		switch a := addr.(type) {
+		case *net.IPNet:
+			ip = a.IP
+		case *net.IPAddr:
+			ip = a.IP
+		default:
+			continue
+		}
+
+		linkLocal := ip.IsLinkLocalUnicast()
+		if linkLocal && noLinkLocal {
+			continue
+		}
+
+		host := ip.String()
+		network := "tcp4"
+
+		if ip.To4() == nil {
+			network = "tcp6"
+			if linkLocal {
+				host += "%" + name
+			}
+		}
+
+		listeners[network] = append(listeners[network], net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+
+	return listeners
+}
@@ -0,0 +1,70 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// TestInterfaceListeners tests how interface addresses are turned into listeners.
+func TestInterfaceListeners(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPAddr{IP: net.ParseIP("169.254.0.1")},
+	}
+
+	expected := map[string][]string{
+		"tcp4": {"192.0.2.1:8080", "169.254.0.1:8080"},
+		"tcp6": {"[2001:db8::1]:8080", "[fe80::1%eth0]:8080"},
+	}
+	if listeners := interfaceListeners("eth0", addrs, 8080, false); !reflect.DeepEqual(listeners, expected) {
+		t.Error("unexpected listeners", listeners)
+	}
+
+	expected = map[string][]string{
+		"tcp4": {"192.0.2.1:8080"},
+		"tcp6": {"[2001:db8::1]:8080"},
+	}
+	if listeners := interfaceListeners("eth0", addrs, 8080, true); !reflect.DeepEqual(listeners, expected) {
+		t.Error("link-local addresses should be skipped", listeners)
+	}
+}
+
+// TestListenInterface tests listening on every address of the loopback interface.
+func TestListenInterface(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal("error listing interfaces", err)
+	}
+
+	var loopback *net.Interface
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagLoopback != 0 {
+			loopback = &ifaces[i]
+			break
+		}
+	}
+	if loopback == nil {
+		t.Skip("no loopback interface")
+	}
+
+	addrs, _ := loopback.Addrs()
+
+	m, err := ListenInterface(loopback.Name, 0)
+	if err != nil {
+		t.Fatal("error listening on interface", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	if info := m.Info(); len(info) != len(addrs) {
+		t.Error("expected a listener per address", info, addrs)
+	}
+
+	_, err = ListenInterface("does-not-exist", 0)
+	if err == nil || errors.Is(err, ErrNoInterfaceAddrs) {
+		t.Error("unknown interfaces should fail the lookup", err)
+	}
+}
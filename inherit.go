@@ -0,0 +1,153 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ErrNotInherited is returned by ListenFromParent when the process was not started with
+// listeners passed by PassFiles.
+var ErrNotInherited = errors.New("no inherited listeners")
+
+// ErrNoFile is returned by PassFiles for listeners that have no file descriptor, such as
+// ones added with RegisterNetwork or handed to New that are not *net.TCPListener or
+// *net.UnixListener.
+var ErrNoFile = errors.New("listener has no file descriptor")
+
+// The variables PassFiles sets for the child, kept apart from the systemd ones since the
+// child's pid is not known before it starts.
+const (
+	inheritFDStartEnv = "MULTILISTENER_FD_START"
+	inheritFDsEnv     = "MULTILISTENER_FDS"
+	inheritNamesEnv   = "MULTILISTENER_FDNAMES"
+)
+
+// filer is implemented by listeners that can hand out a dup of their file descriptor.
+type filer interface {
+	File() (*os.File, error)
+}
+
+// PassFiles hands a dup of every listener's file descriptor to cmd, named by the address it
+// was requested as, so a new version of the binary started by cmd can take over with
+// ListenFromParent while this process drains its conns. The kernel keeps queueing conns in
+// between, so none are refused during the upgrade. The files are appended to cmd.ExtraFiles
+// and should be closed once cmd is started. Unix sockets are no longer unlinked when this
+// multilistener closes, since the child is using them. It is not supported on windows.
+func (m *multiListener) PassFiles(cmd *exec.Cmd) error {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	files := make([]*os.File, 0, len(m.listeners))
+	names := make([]string, 0, len(m.listeners))
+
+	for _, l := range m.listeners {
+		f, err := listenerFile(l)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return err
+		}
+
+		files = append(files, f)
+		names = append(names, l.address)
+	}
+
+	for _, l := range m.listeners {
+		if u, ok := l.Listener.(unlinker); ok {
+			u.SetUnlinkOnClose(false)
+		}
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+
+	cmd.Env = append(cmd.Env,
+		inheritFDStartEnv+"="+strconv.Itoa(activationFDStart+len(cmd.ExtraFiles)),
+		inheritFDsEnv+"="+strconv.Itoa(len(files)),
+		inheritNamesEnv+"="+strings.Join(names, "\n"),
+	)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, files...)
+
+	return nil
+}
+
+// unlinker is implemented by *net.UnixListener, except on plan9.
+type unlinker interface {
+	SetUnlinkOnClose(unlink bool)
+}
+
+// listenerFile returns a dup of the file descriptor of l.
+func listenerFile(l *managedListener) (*os.File, error) {
+	fl, ok := l.Listener.(filer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoFile, l.addr)
+	}
+
+	return fl.File()
+}
+
+// ListenFromParent multiplexes the listeners passed by the parent process through PassFiles,
+// keeping the addresses they were requested as in Info. The variables are unset so child
+// processes do not inherit the sockets. The returned multilistener owns the sockets.
+func ListenFromParent(opts ...Option) (*MultiListener, error) {
+	start := os.Getenv(inheritFDStartEnv)
+	fds := os.Getenv(inheritFDsEnv)
+	names := strings.Split(os.Getenv(inheritNamesEnv), "\n")
+
+	os.Unsetenv(inheritFDStartEnv)
+	os.Unsetenv(inheritFDsEnv)
+	os.Unsetenv(inheritNamesEnv)
+
+	if start == "" || fds == "" {
+		return nil, ErrNotInherited
+	}
+
+	first, err := strconv.Atoi(start)
+	if err != nil || first < activationFDStart {
+		return nil, fmt.Errorf("%w: invalid %s %q", ErrNotInherited, inheritFDStartEnv, start)
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil || n <= 0 || len(names) != n {
+		return nil, fmt.Errorf("%w: invalid %s %q", ErrNotInherited, inheritFDsEnv, fds)
+	}
+
+	files := make([]*os.File, 0, n)
+	for i := 0; i < n; i++ {
+		files = append(files, os.NewFile(uintptr(first+i), names[i]))
+	}
+
+	listeners, err := fileListeners(files)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMultiListener(opts...)
+	if err != nil {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	for i, nL := range listeners {
+		m.register(nL, nL.Addr().Network(), names[i], !m.opts.disowned)
+	}
+
+	for _, l := range m.listeners {
+		m.start(l)
+	}
+
+	m.run()
+
+	return m.handle(), nil
+}
@@ -0,0 +1,107 @@
+//go:build !windows && !plan9
+
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// TestPassFiles tests handing the listeners over to a child process by running the test
+// binary as the child.
+func TestPassFiles(t *testing.T) {
+	if os.Getenv("MULTILISTENER_INHERIT_HELPER") != "" {
+		inheritHelper()
+		return
+	}
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPassFiles$")
+	cmd.Env = append(os.Environ(), "MULTILISTENER_INHERIT_HELPER="+m.Addr().String())
+
+	err = m.(*MultiListener).PassFiles(cmd)
+	if err != nil {
+		t.Fatal("error when passing files", err)
+	}
+
+	// The child takes over once the parent is gone.
+	m.Close()
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Error("child process failed", err, string(out))
+	}
+
+	for _, f := range cmd.ExtraFiles {
+		f.Close()
+	}
+}
+
+// inheritHelper runs in the child process and exits with its result.
+func inheritHelper() {
+	m, err := ListenFromParent()
+	if err != nil {
+		println("error when listening from parent", err.Error())
+		os.Exit(1)
+	}
+	defer m.Close()
+
+	address := os.Getenv("MULTILISTENER_INHERIT_HELPER")
+	if m.Addr().String() != address || m.Info()[0].RequestedAddr != "127.0.0.1:0" {
+		println("unexpected listener", m.Addr().String(), m.Info()[0].RequestedAddr)
+		os.Exit(1)
+	}
+
+	c, err := net.Dial("tcp", address)
+	if err != nil {
+		println("error when dialing", err.Error())
+		os.Exit(1)
+	}
+	c.Close()
+
+	conn, err := m.Accept()
+	if err != nil {
+		println("error when accepting", err.Error())
+		os.Exit(1)
+	}
+	conn.Close()
+}
+
+// TestListenFromParentNone tests that a process started without PassFiles gets ErrNotInherited.
+func TestListenFromParentNone(t *testing.T) {
+	t.Setenv("MULTILISTENER_FDS", "")
+
+	_, err := ListenFromParent()
+	if !errors.Is(err, ErrNotInherited) {
+		t.Error("no inherited fds should return ErrNotInherited", err)
+	}
+}
+
+// TestPassFilesNoFile tests that listeners without a file descriptor can not be passed.
+func TestPassFilesNoFile(t *testing.T) {
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		select {}
+	})})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	cmd := exec.Command(os.Args[0])
+	if err := m.PassFiles(cmd); !errors.Is(err, ErrNoFile) {
+		t.Error("fake listeners should return ErrNoFile", err)
+	}
+
+	if len(cmd.ExtraFiles) != 0 || cmd.Env != nil {
+		t.Error("cmd should be left alone on failure", cmd.ExtraFiles, cmd.Env)
+	}
+}
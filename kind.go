@@ -0,0 +1,88 @@
+package multilistener
+
+import "net"
+
+// ListenerKind is the transport family of a listener.
+type ListenerKind int
+
+// The transport families a listener can have.
+const (
+	Unknown ListenerKind = iota
+	TCP
+	TCP4
+	TCP6
+	Unix
+	UnixPacket
+)
+
+// String implements fmt.Stringer.
+func (k ListenerKind) String() string {
+	switch k {
+	case TCP:
+		return "tcp"
+	case TCP4:
+		return "tcp4"
+	case TCP6:
+		return "tcp6"
+	case Unix:
+		return "unix"
+	case UnixPacket:
+		return "unixpacket"
+	default:
+		return "unknown"
+	}
+}
+
+// KindOf returns the kind of a network string as passed to Listen.
+func KindOf(network string) ListenerKind {
+	switch network {
+	case "tcp":
+		return TCP
+	case "tcp4":
+		return TCP4
+	case "tcp6":
+		return TCP6
+	case "unix":
+		return Unix
+	case "unixpacket":
+		return UnixPacket
+	default:
+		return Unknown
+	}
+}
+
+// ConnKind returns the kind of an accepted conn, looking through any wrappers. TCP conns
+// always report TCP4 or TCP6 based on their local address, since a conn is only ever one.
+func ConnKind(c net.Conn) ListenerKind {
+	for {
+		nc, ok := c.(netConner)
+		if !ok {
+			break
+		}
+		c = nc.NetConn()
+	}
+
+	switch addr := c.LocalAddr().(type) {
+	case *net.TCPAddr:
+		if addr.IP.To4() != nil {
+			return TCP4
+		}
+		return TCP6
+	case *net.UnixAddr:
+		return KindOf(addr.Net)
+	default:
+		return Unknown
+	}
+}
+
+// Kinds returns the kind of every listener, derived from the network it was bound with.
+func (m *multiListener) Kinds() map[net.Addr]ListenerKind {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	kinds := map[net.Addr]ListenerKind{}
+	for addr, l := range m.listeners {
+		kinds[addr] = l.kind
+	}
+	return kinds
+}
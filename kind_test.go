@@ -0,0 +1,55 @@
+package multilistener
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// TestKinds tests deriving listener and conn kinds.
+func TestKinds(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp6": {"[::1]:0"},
+		"unix": {filepath.Join(t.TempDir(), "kinds.sock")},
+	}, WithTLS(&tls.Config{}))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	for addr, kind := range m.(*MultiListener).Kinds() {
+		expected := TCP6
+		if addr.Network() == "unix" {
+			expected = Unix
+		}
+
+		if kind != expected {
+			t.Error("listener kind should match its network", addr, kind)
+		}
+
+		client, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatal("error connecting to listener", err)
+		}
+		defer client.Close()
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error accepting from listener", err)
+		}
+		defer c.Close()
+
+		if ConnKind(c) != expected {
+			t.Error("conn kind should match its listener, even through tls", c.LocalAddr(), ConnKind(c))
+		}
+	}
+
+	if KindOf("udp") != Unknown || Unknown.String() != "unknown" || TCP4.String() != "tcp4" {
+		t.Error("unknown networks should map to Unknown")
+	}
+}
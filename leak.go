@@ -0,0 +1,40 @@
+package multilistener
+
+import (
+	"log/slog"
+	"runtime"
+)
+
+// WithLeakDetector logs a warning if the multilistener is garbage collected without having
+// been closed, to catch missing Close calls in tests and development. It goes through
+// WithLogger, or slog.Default without one.
+//
+// It relies on a finalizer, so it only fires once nothing references the multilistener
+// anymore. Its own accept routines do not keep it reachable, so one dropped while still
+// accepting is caught as well. The finalizer is only set when the option is used.
+func WithLeakDetector() Option {
+	return func(o *options) {
+		o.leakDetector = true
+	}
+}
+
+// detectLeak sets the finalizer of WithLeakDetector on m.
+func (m *MultiListener) detectLeak() {
+	if !m.opts.leakDetector {
+		return
+	}
+
+	runtime.SetFinalizer(m, func(m *MultiListener) {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+
+		logger := m.opts.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Warn("multilistener was garbage collected without being closed")
+	})
+}
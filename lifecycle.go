@@ -0,0 +1,46 @@
+package multilistener
+
+import "net"
+
+// LifecycleEvent is a state change of a single listener, see WithListenerLifecycleHook.
+type LifecycleEvent int
+
+const (
+	// ListenerStarted is sent once the listener's accept routine starts accepting.
+	ListenerStarted LifecycleEvent = iota
+	// ListenerStopped is sent once the listener stopped accepting, such as after Close
+	// or RemoveListener.
+	ListenerStopped
+	// ListenerFailed is sent instead of ListenerStopped when the listener was dropped after
+	// a panic in its accept routine.
+	ListenerFailed
+)
+
+// String implements fmt.Stringer.
+func (e LifecycleEvent) String() string {
+	switch e {
+	case ListenerStarted:
+		return "started"
+	case ListenerStopped:
+		return "stopped"
+	case ListenerFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// WithListenerLifecycleHook calls hook with the address of each listener as it starts and
+// stops accepting. The hook is called from accept routines without holding any lock, so it
+// may call back into the multilistener, but it should not block.
+func WithListenerLifecycleHook(hook func(addr net.Addr, event LifecycleEvent)) Option {
+	return func(o *options) {
+		o.lifecycleHook = hook
+	}
+}
+
+// lifecycle sends event for the listener at addr to the lifecycle hook.
+func (m *multiListener) lifecycle(addr net.Addr, event LifecycleEvent) {
+	if m.opts.lifecycleHook != nil {
+		m.opts.lifecycleHook(addr, event)
+	}
+}
@@ -0,0 +1,31 @@
+package multilistener
+
+// Merge unions network->[]address maps, such as config fragments from several sources,
+// into one that can be passed to Listen. Addresses keep the order in which they first appear,
+// going through the maps in order, and repeats are dropped. Addresses are compared as given,
+// so different spellings of the same address, such as ":80" and "0.0.0.0:80", are both kept.
+// A network listed without addresses is kept with an empty list.
+func Merge(maps ...map[string][]string) map[string][]string {
+	merged := map[string][]string{}
+	seen := map[pair]struct{}{}
+
+	for _, listeners := range maps {
+		for network, addresses := range listeners {
+			if _, ok := merged[network]; !ok {
+				merged[network] = []string{}
+			}
+
+			for _, address := range addresses {
+				p := pair{network: network, address: address}
+				if _, ok := seen[p]; ok {
+					continue
+				}
+
+				seen[p] = struct{}{}
+				merged[network] = append(merged[network], address)
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,35 @@
+package multilistener
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMerge tests that Merge unions address lists in first-seen order without repeats.
+func TestMerge(t *testing.T) {
+	merged := Merge(
+		map[string][]string{
+			"tcp":  {"127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8080"},
+			"unix": {},
+		},
+		nil,
+		map[string][]string{
+			"tcp":  {"127.0.0.1:8082", "127.0.0.1:8081"},
+			"tcp6": {"[::1]:8080"},
+		},
+	)
+
+	expected := map[string][]string{
+		"tcp":  {"127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082"},
+		"tcp6": {"[::1]:8080"},
+		"unix": {},
+	}
+
+	if !reflect.DeepEqual(merged, expected) {
+		t.Error("unexpected merge result", merged)
+	}
+
+	if merged := Merge(); len(merged) != 0 {
+		t.Error("merging nothing should be empty", merged)
+	}
+}
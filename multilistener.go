@@ -2,80 +2,693 @@
 package multilistener
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var ErrClosed = errors.New("listener is already closed")
+// ErrClosed is returned once the multilistener is closed. It matches net.ErrClosed.
+var ErrClosed = fmt.Errorf("%w: listener is already closed", net.ErrClosed)
+
+// ErrLifetimeDone is the CloseReason of a multilistener closed by WithLifetimeContext.
+var ErrLifetimeDone = errors.New("lifetime context is done")
+
+// ErrStopChannelClosed is the CloseReason of a multilistener closed by WithStopChannel.
+var ErrStopChannelClosed = errors.New("stop channel was closed")
+
+// ErrListenerNotFound is returned when an address is not part of the multilistener.
+var ErrListenerNotFound = errors.New("listener not found")
+
+// ErrPanic is reported when an accept routine recovers from a panic.
+var ErrPanic = errors.New("accept routine panicked")
+
+// ErrNilConn is returned by Accept when an underlying listener misbehaves and returns
+// neither a conn nor an error.
+var ErrNilConn = errors.New("listener returned a nil conn without an error")
+
+// ErrReusePortUnsupported is returned when WithReusePort is used on a platform without SO_REUSEPORT.
+var ErrReusePortUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// ErrFastOpenUnsupported is returned when WithTCPFastOpen is used on a platform without TCP Fast Open.
+var ErrFastOpenUnsupported = errors.New("TCP Fast Open is not supported on this platform")
+
+// ErrInvalidOption is returned when an option is given a value it can not use.
+var ErrInvalidOption = errors.New("invalid option")
 
 type chanMsg struct {
 	conn net.Conn
 	err  error
+	addr net.Addr
+}
+
+// ListenerInfo describes what was requested for a listener and what it was bound to.
+type ListenerInfo struct {
+	Network       string
+	RequestedAddr string
+	ResolvedAddr  string
+}
+
+// managedListener is an underlying listener along with the state of its accept routine.
+type managedListener struct {
+	net.Listener
+	network   string
+	address   string
+	addr      net.Addr
+	kind      ListenerKind
+	owned     bool
+	quit      chan struct{}
+	ready     chan struct{}
+	readyOnce sync.Once
+	tlsConfig *tls.Config
+	proxy     bool
+
+	// slots holds a token per open conn when ListenerSpec.MaxConns is set.
+	slots chan struct{}
+
+	// weight and offer are used by WithListenerWeights, offer holds the result l is ready with.
+	weight int
+	offer  chan chanMsg
+
+	// accepted, errors and lastErr are reported by Stats.
+	accepted atomic.Int64
+	errors   atomic.Int64
+	lastErr  atomic.Pointer[error]
+
+	// workers counts the running accept routines, failed is set once l was dropped.
+	workers atomic.Int32
+	failed  atomic.Bool
+
+	// lastActivity is when accept last returned, in unix nanoseconds.
+	lastActivity atomic.Int64
+	idle         atomic.Bool
+}
+
+// untilAcceptor is implemented by listeners whose accept can be abandoned without losing a conn.
+type untilAcceptor interface {
+	acceptUntil(stop <-chan struct{}, quit <-chan struct{}) (net.Conn, error)
+}
+
+// accept waits for the next conn from the underlying listener.
+func (l *managedListener) accept(stop <-chan struct{}) (net.Conn, error) {
+	if u, ok := l.Listener.(untilAcceptor); ok {
+		return u.acceptUntil(stop, l.quit)
+	}
+	return l.Accept()
+}
+
+// keepSettings carries the per-listener settings of old over to l, which takes its place.
+func (l *managedListener) keepSettings(old *managedListener) {
+	l.address = old.address
+	l.tlsConfig = old.tlsConfig
+	l.proxy = old.proxy
+	l.slots = old.slots
+	l.weight = old.weight
+}
+
+// release closes the underlying listener if the multilistener owns it.
+func (l *managedListener) release() error {
+	if !l.owned {
+		return nil
+	}
+	return l.Close()
+}
+
+// wrap runs a conn freshly accepted from l through the conn pipeline. Socket buffer sizes
+// are set on the raw conn first, then stages are applied in a fixed order, each wrapping
+// the result of the previous one:
+//
+//  1. ListenerSpec.MaxConns accounting
+//  2. live conn tracking
+//  3. WithConnContext
+//  4. WithFirstByteObserver
+//  5. WithConnTap
+//  6. WithProxyProtocol
+//  7. WithTLS
+//  8. WithConnPipeline stages, in the order given
+//  9. WithAcceptFilter
+//
+// If a stage fails the conn is closed and the error is returned. A conn vetoed by the
+// filter is counted as rejected and errRejected is returned.
+func (m *multiListener) wrap(l *managedListener, c net.Conn) (net.Conn, error) {
+	m.setBuffers(l, c)
+
+	if l.slots != nil {
+		c = &limitConn{wrappedConn: wrappedConn{Conn: c}, slots: l.slots}
+	}
+	if m.opts.tracking() {
+		c = m.track(c)
+	}
+	if m.opts.connContext != nil {
+		c = &contextConn{wrappedConn: wrappedConn{Conn: c}, ctx: m.opts.connContext(m.ctx, l.addr)}
+	}
+	if m.opts.firstByte != nil {
+		c = &firstByteConn{wrappedConn: wrappedConn{Conn: c}, addr: l.addr, accepted: m.opts.clock.Now(), clock: m.opts.clock, observe: m.opts.firstByte}
+	}
+	if m.opts.tap != nil {
+		c = &tapConn{wrappedConn: wrappedConn{Conn: c}, tap: m.opts.tap}
+	}
+	if l.proxy {
+		c = m.newProxyConn(l, c)
+	}
+	if l.tlsConfig != nil {
+		c = m.tlsServer(l, c)
+	}
+
+	for _, stage := range m.opts.pipeline {
+		next, err := stage(c)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = next
+	}
+
+	if m.opts.acceptFilter != nil {
+		next, err := m.opts.acceptFilter(c)
+		if err != nil {
+			c.Close()
+			m.rejected.Add(1)
+			return nil, errRejected
+		}
+		c = next
+	}
+
+	return c, nil
+}
+
+// setBuffers applies the configured socket buffer sizes to a raw TCP conn from l. Failures
+// are reported but do not reject the conn.
+func (m *multiListener) setBuffers(l *managedListener, c net.Conn) {
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	if size, ok := m.opts.bufferSize(m.opts.readBuffers, l); ok {
+		if err := tc.SetReadBuffer(size); err != nil {
+			m.report(l.addr, err)
+		}
+	}
+
+	if size, ok := m.opts.bufferSize(m.opts.writeBuffers, l); ok {
+		if err := tc.SetWriteBuffer(size); err != nil {
+			m.report(l.addr, err)
+		}
+	}
 }
 
 // MultiListener is the main multilistener struct.
 type MultiListener struct {
+	*multiListener
+}
+
+// multiListener is the state behind a MultiListener. Accept routines and callbacks only
+// reference it, never the MultiListener handed to callers, so WithLeakDetector can tell when
+// callers dropped theirs.
+type multiListener struct {
 	mut       *sync.RWMutex
-	listeners map[net.Addr]net.Listener
+	listeners map[net.Addr]*managedListener
+	requested []pair
+	bindErrs  []error
 	accept    chan chanMsg
-	stop      chan struct{}
-}
+	opts      options
+	ctx       context.Context
+	routines  *sync.WaitGroup
 
-// Network implements net.Addr.
-func (m *MultiListener) Network() string {
-	m.mut.RLock()
-	defer m.mut.RUnlock()
+	// life is canceled with the close reason by Close, and stop is its Done channel.
+	life   context.Context
+	cancel context.CancelCauseFunc
+	stop   <-chan struct{}
+
+	// empty is closed while there are no listeners, when WithNoListenersError is used.
+	empty   chan struct{}
+	isEmpty bool
+
+	connMut *sync.Mutex
+	conns   map[*trackedConn]struct{}
+
+	gate     chan struct{}
+	paused   atomic.Bool
+	draining atomic.Bool
+	drained  atomic.Uint64
+
+	errMut     *sync.Mutex
+	coalesced  map[net.Addr]*coalescedError
+	errs       chan ListenerError
+	errsClosed bool
+	dropped    atomic.Uint64
+
+	rejected atomic.Uint64
+	waiting  atomic.Int64
 
+	// deadline is set by SetDeadline.
+	deadline *deadline
+
+	// cooldown is when a WithFDExhaustionCooldown pause ends, in unix nanoseconds.
+	cooldown atomic.Int64
+
+	// ready lists the listeners holding a result for WithListenerWeights. wake is signaled while
+	// it is not empty, and nil otherwise.
+	readyMut *sync.Mutex
+	ready    []*managedListener
+	wake     chan struct{}
+}
+
+// Network implements net.Addr. It has one entry per listener, lined up with String, so
+// networks repeat; use Networks for the distinct ones.
+func (m *multiListener) Network() string {
 	a := []string{}
-	for addr := range m.listeners {
+	for _, addr := range m.Addresses() {
 		a = append(a, addr.Network())
 	}
-	return strings.Join(a, ";")
+	return strings.Join(a, m.opts.addrSeparator)
 }
 
-// String implements net.Addr.
-func (m *MultiListener) String() string {
-	m.mut.RLock()
-	defer m.mut.RUnlock()
+// Networks returns the distinct networks of the listeners, sorted, such as [tcp unix].
+func (m *multiListener) Networks() []string {
+	networks := []string{}
+	for _, addr := range m.Addresses() {
+		if !slices.Contains(networks, addr.Network()) {
+			networks = append(networks, addr.Network())
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
 
+// String implements net.Addr.
+func (m *multiListener) String() string {
 	a := []string{}
-	for addr := range m.listeners {
+	for _, addr := range m.Addresses() {
 		a = append(a, addr.String())
 	}
-	return strings.Join(a, ";")
+	return strings.Join(a, m.opts.addrSeparator)
 }
 
 // Addresses returns a slice of addresses. This is not ordered.
-func (m *MultiListener) Addresses() []net.Addr {
+// The slice is a snapshot taken under the lock, so its values stay valid even if
+// a listener is removed right after the call returns.
+func (m *multiListener) Addresses() []net.Addr {
 	m.mut.RLock()
 	defer m.mut.RUnlock()
 
 	a := []net.Addr{}
-	for addr := range m.listeners {
-		a = append(a, addr)
+	for _, l := range m.listeners {
+		if l.addr == nil {
+			continue
+		}
+		a = append(a, l.addr)
 	}
 	return a
 }
 
-// Accept implements net.Listener.
-func (m *MultiListener) Accept() (net.Conn, error) {
+// Info returns what each listener was requested as and what the kernel bound it to,
+// such as the port picked for ":0". This is not ordered.
+func (m *multiListener) Info() []ListenerInfo {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	info := []ListenerInfo{}
+	for _, l := range m.listeners {
+		info = append(info, ListenerInfo{
+			Network:       l.network,
+			RequestedAddr: l.address,
+			ResolvedAddr:  l.addr.String(),
+		})
+	}
+	return info
+}
+
+// Requested returns a copy of the network->[]address pairs the multilistener was asked to
+// bind by Listen or ListenConfig, as given, including any WithMinListeners tolerated failing.
+// Listeners added or removed later are not reflected; use Info for what is bound now. It is
+// nil for multilisteners created by New.
+func (m *multiListener) Requested() map[string][]string {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	if m.requested == nil {
+		return nil
+	}
+
+	requested := map[string][]string{}
+	for _, p := range m.requested {
+		requested[p.network] = append(requested[p.network], p.address)
+	}
+	return requested
+}
+
+// BindErrors returns a copy of the bind failures Listen or ListenConfig tolerated because of
+// WithMinListeners or WithPartialFailure, each a *ListenError naming its network and address.
+// errors.Join combines them into one. It is empty when everything bound.
+func (m *multiListener) BindErrors() []error {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return slices.Clone(m.bindErrs)
+}
+
+// Accept implements net.Listener. Conns from a single listener are handed out in the order
+// the kernel accepted them, with or without WithAcceptBuffer, since each listener has one
+// accept routine feeding a FIFO channel, unless WithAcceptWorkers adds more. Conns from
+// different listeners may interleave.
+//
+// Accept is safe to call from many goroutines. Waiting callers are handed conns in the order
+// they started waiting, so a pool of callers shares the load, and every one of them returns
+// ErrClosed once the multilistener is closed.
+func (m *multiListener) Accept() (net.Conn, error) {
+	if m.opts.pauseErr && m.paused.Load() {
+		return nil, ErrPaused
+	}
+	res := m.next(nil, nil, m.emptied())
+	return res.conn, res.err
+}
+
+// AcceptFrom accepts like Accept and also returns the address of the listener the conn or
+// error came from. The address is nil when the multilistener itself is closed or paused.
+//
+// The listener address is the one the listener was bound to, which differs from the conn's
+// LocalAddr for wildcard binds: a conn accepted by a listener on 0.0.0.0:8080 has a concrete
+// local address like 192.0.2.1:8080, while AcceptFrom returns 0.0.0.0:8080.
+func (m *multiListener) AcceptFrom() (net.Conn, net.Addr, error) {
+	if m.opts.pauseErr && m.paused.Load() {
+		return nil, nil, ErrPaused
+	}
+
+	res := m.next(nil, nil, m.emptied())
+	return res.conn, res.addr, res.err
+}
+
+// acceptUntil accepts like Accept but gives up without consuming a conn once stop or quit is closed.
+// This lets a parent multilistener stop accepting from a child without stealing its conns.
+func (m *multiListener) acceptUntil(stop <-chan struct{}, quit <-chan struct{}) (net.Conn, error) {
+	res := m.next(stop, quit, nil)
+	return res.conn, res.err
+}
+
+// next waits for the next accept result until the multilistener, stop or quit is closed,
+// or empty is closed and nothing is left to hand out.
+func (m *multiListener) next(stop <-chan struct{}, quit <-chan struct{}, empty <-chan struct{}) chanMsg {
+	m.waiting.Add(1)
+	defer m.waiting.Add(-1)
+
+	for {
+		select {
+		case <-m.resumed():
+		case <-m.stop:
+			return chanMsg{err: ErrClosed}
+		case <-stop:
+			return chanMsg{err: ErrClosed}
+		case <-quit:
+			return chanMsg{err: ErrClosed}
+		case <-m.deadline.done():
+			return chanMsg{err: m.timeout()}
+		}
+
+		select {
+		case <-m.stop:
+			return chanMsg{err: ErrClosed}
+		case <-stop:
+			return chanMsg{err: ErrClosed}
+		case <-quit:
+			return chanMsg{err: ErrClosed}
+		case res := <-m.accept:
+			m.hold()
+			return m.received(res)
+		case <-m.wake:
+			if res, ok := m.take(); ok {
+				m.hold()
+				return m.received(res)
+			}
+		case <-empty:
+			res := m.leftover()
+			m.hold()
+			return m.received(res)
+		case <-m.deadline.done():
+			return chanMsg{err: m.timeout()}
+		}
+	}
+}
+
+// timeout is the error accepts fail with once a deadline passed, like that of *net.TCPListener.
+func (m *multiListener) timeout() error {
+	return &net.OpError{Op: "accept", Net: m.Network(), Addr: m, Err: os.ErrDeadlineExceeded}
+}
+
+// SetDeadline makes Accept and its variants that block fail with a net.Error with Timeout()
+// set once t has passed, like *net.TCPListener.SetDeadline. Accepts already waiting are
+// affected as well. A zero t clears the deadline. Unlike the deadline of a *net.TCPListener,
+// it does not stop the accept routines, so conns keep being accepted in the background.
+func (m *multiListener) SetDeadline(t time.Time) error {
+	m.deadline.set(t)
+	return nil
+}
+
+// received returns res unless the multilistener was closed meanwhile. A select picks at
+// random between a pending result and a closed stop, and the result may well be the error
+// of a listener unblocked by Close, so closing always wins and presents as ErrClosed.
+func (m *multiListener) received(res chanMsg) chanMsg {
 	select {
 	case <-m.stop:
-		return nil, ErrClosed
+		if res.conn != nil {
+			res.conn.Close()
+		}
+		return chanMsg{err: ErrClosed}
+	default:
+		return res
+	}
+}
+
+// leftover hands out a conn still buffered once there are no listeners left, or ErrNoListeners.
+func (m *multiListener) leftover() chanMsg {
+	if res, ok := m.take(); ok {
+		return res
+	}
+
+	select {
 	case res := <-m.accept:
-		return res.conn, res.err
+		return res
+	default:
+		return chanMsg{err: ErrNoListeners}
+	}
+}
+
+// AcceptTimeout waits up to d for the next connection. If none arrives in time, or before
+// the deadline set by SetDeadline, a net.Error with Timeout() set is returned. Other callers
+// of Accept are unaffected.
+func (m *multiListener) AcceptTimeout(d time.Duration) (net.Conn, error) {
+	if m.opts.pauseErr && m.paused.Load() {
+		return nil, ErrPaused
+	}
+
+	timer := m.opts.clock.NewTimer(d)
+	defer timer.Stop()
+
+	m.waiting.Add(1)
+	defer m.waiting.Add(-1)
+
+	for {
+		select {
+		case <-m.resumed():
+		case <-m.stop:
+			return nil, ErrClosed
+		case <-timer.C():
+			return nil, m.timeout()
+		case <-m.deadline.done():
+			return nil, m.timeout()
+		}
+
+		select {
+		case <-m.stop:
+			return nil, ErrClosed
+		case res := <-m.accept:
+			m.hold()
+			res = m.received(res)
+			return res.conn, res.err
+		case <-m.wake:
+			if res, ok := m.take(); ok {
+				m.hold()
+				res = m.received(res)
+				return res.conn, res.err
+			}
+		case <-m.emptied():
+			res := m.leftover()
+			m.hold()
+			res = m.received(res)
+			return res.conn, res.err
+		case <-timer.C():
+			return nil, m.timeout()
+		case <-m.deadline.done():
+			return nil, m.timeout()
+		}
+	}
+}
+
+// TryAccept accepts like Accept without blocking, for polling from an event loop. It returns
+// true with the conn or error if one was ready, or false if nothing was. Once the
+// multilistener is closed it returns true with ErrClosed.
+func (m *multiListener) TryAccept() (net.Conn, bool, error) {
+	select {
+	case <-m.stop:
+		return nil, true, ErrClosed
+	default:
+	}
+
+	if m.paused.Load() {
+		if m.opts.pauseErr {
+			return nil, true, ErrPaused
+		}
+		return nil, false, nil
+	}
+
+	if res, ok := m.take(); ok {
+		res = m.received(res)
+		return res.conn, true, res.err
+	}
+
+	select {
+	case res := <-m.accept:
+		res = m.received(res)
+		return res.conn, true, res.err
+	case <-m.emptied():
+		res := m.received(m.leftover())
+		return res.conn, true, res.err
+	default:
+		return nil, false, nil
 	}
 }
 
-// Addr implements net.Listener.
+// AcceptN accepts up to n connections. It stops early when the multilistener is closed,
+// the context is done, the deadline set by SetDeadline passed or an accept fails, returning
+// the connections collected so far along with the error that ended the batch. The caller
+// owns every returned connection. It returns nothing for n of 0 or less.
+func (m *multiListener) AcceptN(ctx context.Context, n int) ([]net.Conn, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	if m.opts.pauseErr && m.paused.Load() {
+		return nil, ErrPaused
+	}
+
+	conns := make([]net.Conn, 0, n)
+
+	m.waiting.Add(1)
+	defer m.waiting.Add(-1)
+
+	for len(conns) < n {
+		var res chanMsg
+
+		select {
+		case <-m.resumed():
+		case <-m.stop:
+			return conns, ErrClosed
+		case <-ctx.Done():
+			return conns, ctx.Err()
+		case <-m.deadline.done():
+			return conns, m.timeout()
+		}
+
+		select {
+		case <-m.stop:
+			return conns, ErrClosed
+		case <-ctx.Done():
+			return conns, ctx.Err()
+		case <-m.deadline.done():
+			return conns, m.timeout()
+		case res = <-m.accept:
+		case <-m.wake:
+			var ok bool
+			if res, ok = m.take(); !ok {
+				continue
+			}
+		case <-m.emptied():
+			res = m.leftover()
+		}
+		m.hold()
+		res = m.received(res)
+
+		if res.err != nil {
+			return conns, res.err
+		}
+		conns = append(conns, res.conn)
+	}
+
+	return conns, nil
+}
+
+// Addr implements net.Listener. It returns the multilistener itself, whose Network and
+// String join every listener, unless WithPrimaryAddr designates a single address.
 func (m *MultiListener) Addr() net.Addr {
+	if addr := m.PrimaryAddr(); addr != nil {
+		return addr
+	}
 	return m
 }
 
+// PrimaryAddr returns the bound address designated by WithPrimaryAddr, or nil if there
+// is none or it is not bound.
+func (m *multiListener) PrimaryAddr() net.Addr {
+	p := m.opts.primary
+	if p == nil {
+		return nil
+	}
+
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	for _, l := range m.listeners {
+		if l.addr.Network() == p.Network() && (l.addr.String() == p.String() || l.address == p.String()) {
+			return l.addr
+		}
+	}
+	return nil
+}
+
 // Close implements net.Listener.
-func (m *MultiListener) Close() error {
+func (m *multiListener) Close() error {
+	return m.close(m.opts.closeConns, ErrClosed)
+}
+
+// CloseReason returns why the multilistener was closed, or nil while it is open: ErrClosed
+// after Close or Shutdown, an error matching ErrLifetimeDone and the cause of the context
+// after WithLifetimeContext, and ErrStopChannelClosed after WithStopChannel. Accept returns
+// ErrClosed either way.
+func (m *multiListener) CloseReason() error {
+	select {
+	case <-m.stop:
+		return context.Cause(m.life)
+	default:
+		return nil
+	}
+}
+
+// Done returns a channel that is closed once the multilistener is closed.
+func (m *multiListener) Done() <-chan struct{} {
+	return m.stop
+}
+
+// Context returns a context that is canceled once the multilistener is closed, with the
+// CloseReason as its cause, for tying other work to the lifetime of the listeners.
+func (m *multiListener) Context() context.Context {
+	return m.life
+}
+
+// close closes every listener for reason, and every tracked conn if closeConns is set.
+func (m *multiListener) close(closeConns bool, reason error) error {
+	// Summaries go to the error hook, which must not run under the lock.
+	m.flushCoalesced()
+
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
@@ -83,59 +696,867 @@ func (m *MultiListener) Close() error {
 	case <-m.stop:
 		return ErrClosed
 	default:
+		// Stop first, so the errors of the listeners unblocked below are never handed out.
+		m.cancel(reason)
+		m.draining.Store(false)
+
 		closeErrs := []error{}
 
 		for _, l := range m.listeners {
-			err := l.Close()
+			err := l.release()
 			if err != nil {
 				closeErrs = append(closeErrs, err)
 			}
 		}
 
-		close(m.stop)
+		m.drainBuffer()
+		m.discardOffers()
+
+		if closeConns {
+			m.closeConns()
+		}
+
+		m.closeErrors()
 
 		return errors.Join(closeErrs...)
 	}
 }
 
-// Listen listens on multiple network->[]address pairs as defined in the map.
-func Listen(listeners map[string][]string) (net.Listener, error) {
-	m := &MultiListener{
-		mut:       &sync.RWMutex{},
-		listeners: map[net.Addr]net.Listener{},
-		accept:    make(chan chanMsg),
-		stop:      make(chan struct{}),
+// CloseOnce closes like Close but returns nil if the multilistener was already closed,
+// which suits deferred cleanup that may run after an explicit Close.
+func (m *multiListener) CloseOnce() error {
+	err := m.Close()
+	if err == ErrClosed {
+		return nil
+	}
+	return err
+}
+
+// QueueDepth returns how many accepted conns are waiting to be handed out by Accept. Only a
+// WithAcceptBuffer buffer can hold conns, so it is always 0 without one. The value is read
+// without locking and may be stale by the time it is returned, so treat it as a pressure
+// signal rather than an exact count.
+func (m *multiListener) QueueDepth() int {
+	return len(m.accept)
+}
+
+// WaitingAcceptors returns how many callers are blocked in Accept or one of its variants.
+// Zero while QueueDepth is high suggests the consumer loop stalled, while many waiting
+// callers with an empty queue suggest more callers than needed. Parent multilisteners made
+// with Combine count as callers of their children.
+func (m *multiListener) WaitingAcceptors() int {
+	return int(m.waiting.Load())
+}
+
+// drainBuffer closes conns that were accepted into the buffer but never handed out.
+func (m *multiListener) drainBuffer() {
+	for {
+		select {
+		case res := <-m.accept:
+			if res.conn != nil {
+				res.conn.Close()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// WaitReady blocks until every current accept routine has entered its accept loop
+// or the context is done.
+func (m *multiListener) WaitReady(ctx context.Context) error {
+	m.mut.RLock()
+	ready := []chan struct{}{}
+	for _, l := range m.listeners {
+		ready = append(ready, l.ready)
+	}
+	m.mut.RUnlock()
+
+	for _, r := range ready {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r:
+		}
 	}
 
+	return nil
+}
+
+// AddListener binds a new network/address pair and starts accepting from it.
+func (m *multiListener) AddListener(network string, address string) (net.Addr, error) {
 	m.mut.Lock()
 	defer m.mut.Unlock()
 
-	for network, addresses := range listeners {
-		for _, address := range addresses {
-			nL, err := net.Listen(network, address)
-			if err != nil {
-				return nil, err
+	select {
+	case <-m.stop:
+		return nil, ErrClosed
+	default:
+	}
+
+	l, err := m.bind(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	m.start(l)
+
+	return l.addr, nil
+}
+
+// RemoveListener stops accepting from the listener bound to addr and closes it.
+// Connections that were already accepted are left untouched.
+func (m *multiListener) RemoveListener(addr net.Addr) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	l, ok := m.listeners[addr]
+	if !ok {
+		return ErrListenerNotFound
+	}
+
+	delete(m.listeners, addr)
+	m.updateEmpty()
+	close(l.quit)
+
+	return l.release()
+}
+
+// FindAddr looks up the stored address of a listener so it can be passed to RemoveListener
+// or ReplaceListener. address may be the address that was requested or the one that was
+// bound, and tcp addresses are resolved, so "localhost:8080" finds "127.0.0.1:8080".
+func (m *multiListener) FindAddr(network string, address string) (net.Addr, bool) {
+	var resolved *net.TCPAddr
+	if strings.HasPrefix(network, "tcp") {
+		resolved, _ = net.ResolveTCPAddr(network, address)
+	}
+
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	for _, l := range m.listeners {
+		if l.network != network && l.addr.Network() != network {
+			continue
+		}
+
+		if l.address == address || l.addr.String() == address {
+			return l.addr, true
+		}
+
+		if tcpAddr, ok := l.addr.(*net.TCPAddr); ok && resolved != nil {
+			if tcpAddr.IP.Equal(resolved.IP) && tcpAddr.Port == resolved.Port && tcpAddr.Zone == resolved.Zone {
+				return l.addr, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// ReplaceListener swaps the listener bound to addr for newListener, such as one bound to the
+// same address with SO_REUSEPORT, in a single step. The old accept routine is stopped and the
+// old listener is closed if owned, while conns it already accepted stay alive. The new listener
+// keeps the requested address and per-listener settings of the old one.
+func (m *multiListener) ReplaceListener(addr net.Addr, newListener net.Listener) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	select {
+	case <-m.stop:
+		return ErrClosed
+	default:
+	}
+
+	old, ok := m.listeners[addr]
+	if !ok {
+		return ErrListenerNotFound
+	}
+
+	delete(m.listeners, addr)
+	close(old.quit)
+
+	l := m.register(newListener, old.network, old.address, !m.opts.disowned)
+	l.keepSettings(old)
+	m.start(l)
+
+	return old.release()
+}
+
+// Rebind closes the tcp listener bound to addr and binds its requested address again, such
+// as after a DHCP lease or roaming interface changed its IP, re-resolving host names. The
+// port it was bound to is kept, and so are its per-listener settings, while conns it already
+// accepted stay alive. Listeners on wildcard addresses and non tcp listeners are unaffected.
+//
+// If the address can no longer be bound the listener is removed and the *ListenError is
+// returned, matching ErrAddrNotAvailable when no interface has the address anymore.
+func (m *multiListener) Rebind(addr net.Addr) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	select {
+	case <-m.stop:
+		return ErrClosed
+	default:
+	}
+
+	old, ok := m.listeners[addr]
+	if !ok {
+		return ErrListenerNotFound
+	}
+
+	tcpAddr, ok := old.addr.(*net.TCPAddr)
+	if !ok || tcpAddr.IP.IsUnspecified() {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(old.address)
+	if err != nil {
+		return newListenError(old.network, old.address, err)
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
+
+	delete(m.listeners, addr)
+	m.updateEmpty()
+	close(old.quit)
+	releaseErr := old.release()
+
+	l, err := m.bind(old.network, address)
+	if err != nil {
+		if releaseErr == nil {
+			return err
+		}
+		return errors.Join(err, releaseErr)
+	}
+
+	l.keepSettings(old)
+	m.start(l)
+
+	return releaseErr
+}
+
+// bindPair binds p, as one socket per WithReusePortSockets for tcp. If any of them fails the
+// others are closed again. The caller must hold the lock.
+func (m *multiListener) bindPair(p pair) ([]*managedListener, error) {
+	first, err := m.bind(p.network, p.address)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := []*managedListener{first}
+	tcpAddr, ok := first.addr.(*net.TCPAddr)
+	if _, custom := registered(p.network); !ok || custom {
+		return ls, nil
+	}
+
+	// Later sockets join the port the first one was given for ":0".
+	host, _, _ := net.SplitHostPort(p.address)
+	address := net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
+
+	for len(ls) < m.opts.reusePortSockets {
+		l, err := m.bind(p.network, address)
+		if err != nil {
+			for _, l := range ls {
+				delete(m.listeners, l.addr)
+				// The failed bind is the error worth returning for the pair.
+				_ = l.release()
 			}
+			m.updateEmpty()
+			return nil, err
+		}
+
+		l.keepSettings(first)
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
+
+// bind listens on network/address and registers the result. The caller must hold the lock.
+func (m *multiListener) bind(network string, address string) (*managedListener, error) {
+	err := validate(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	start := m.opts.clock.Now()
+	nL, err := m.listen(context.Background(), network, address)
+	m.logBind(network, address, m.opts.clock.Now().Sub(start), err)
+
+	if err != nil {
+		return nil, newListenError(network, address, err)
+	}
+
+	return m.register(nL, network, address, true), nil
+}
+
+// listen binds network/address, dispatching registered networks to their func.
+func (m *multiListener) listen(ctx context.Context, network string, address string) (net.Listener, error) {
+	if fn, ok := registered(network); ok {
+		return fn(ctx, address)
+	}
+
+	lc := net.ListenConfig{Control: m.opts.control, KeepAlive: m.opts.keepAlive}
+	if m.opts.unixStale && unixFile(network, address) {
+		m.removeStale(ctx, network, address)
+	}
+	if m.opts.unixPerms(network, address) {
+		return m.listenUnix(ctx, lc, network, address)
+	}
+
+	nL, err := lc.Listen(ctx, network, address)
+	if err != nil || m.opts.backlog <= 0 || !strings.HasPrefix(network, "tcp") {
+		return nL, err
+	}
 
-			m.listeners[nL.Addr()] = nL
+	if !backlogSupported {
+		if m.opts.logger != nil {
+			m.opts.logger.Warn("listen backlog is not supported on this platform", "network", network, "address", address)
 		}
+		return nL, nil
 	}
 
-	for _, l := range m.listeners {
-		go func(l net.Listener) {
-			for {
-				c, e := l.Accept()
-				msg := chanMsg{conn: c, err: e}
+	err = m.applyBacklog(nL)
+	if err != nil {
+		nL.Close()
+		return nil, err
+	}
+
+	return nL, nil
+}
+
+// applyBacklog sets the configured accept queue size on a freshly bound TCP listener.
+func (m *multiListener) applyBacklog(nL net.Listener) error {
+	tl, ok := nL.(*net.TCPListener)
+	if !ok {
+		return nil
+	}
+
+	rc, err := tl.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = rc.Control(func(fd uintptr) {
+		sockErr = setBacklog(fd, m.opts.backlog)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sockErr
+}
+
+// unbind releases every listener after a failed construction. The caller must hold the lock.
+func (m *multiListener) unbind() {
+	for addr, l := range m.listeners {
+		// The construction already failed with its own error, which is the one worth returning.
+		_ = l.release()
+		delete(m.listeners, addr)
+	}
+	m.updateEmpty()
+}
+
+// register tracks an underlying listener. The caller must hold the lock.
+func (m *multiListener) register(nL net.Listener, network string, address string, owned bool) *managedListener {
+	l := &managedListener{
+		Listener:  nL,
+		network:   network,
+		address:   address,
+		addr:      nL.Addr(),
+		kind:      KindOf(network),
+		owned:     owned,
+		quit:      make(chan struct{}),
+		ready:     make(chan struct{}),
+		tlsConfig: m.opts.tlsConfig(address),
+		proxy:     m.opts.proxyProtocol(address),
+	}
+	if m.opts.weights != nil {
+		l.weight = m.opts.weightOf(l)
+		l.offer = make(chan chanMsg, 1)
+	}
+	l.lastActivity.Store(m.opts.clock.Now().UnixNano())
+
+	m.listeners[l.addr] = l
+	m.updateEmpty()
+
+	return l
+}
+
+// start launches the accept routine for l.
+func (m *multiListener) start(l *managedListener) {
+	workers := m.opts.acceptWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	l.workers.Store(int32(workers))
+	for i := 0; i < workers; i++ {
+		m.goRoutine(func() { m.serve(l) })
+	}
+}
+
+// goRoutine runs fn in a goroutine that Wait waits for.
+func (m *multiListener) goRoutine(fn func()) {
+	m.routines.Add(1)
+	go func() {
+		defer m.routines.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until the multilistener is closed, which also closes the listeners it owns, and
+// every goroutine it started has exited, so shutdown can be written as Close followed by Wait.
+// It returns immediately once that has happened, and for a MultiListener that was never created
+// by Listen, New or Combine. Any number of callers may wait. Accept routines of listeners the
+// multilistener does not own, such as those passed to Combine, only exit once their pending
+// Accept returns.
+func (m *multiListener) Wait() {
+	if m == nil || m.stop == nil {
+		return
+	}
+
+	<-m.stop
+	m.routines.Wait()
+}
+
+// serve forwards everything accepted by l until l is removed or m is closed.
+func (m *multiListener) serve(l *managedListener) {
+	l.readyOnce.Do(func() {
+		close(l.ready)
+		m.lifecycle(l.addr, ListenerStarted)
+	})
+
+	defer func() {
+		if l.workers.Add(-1) == 0 && !l.failed.Load() {
+			m.lifecycle(l.addr, ListenerStopped)
+		}
+	}()
+
+	for m.serveLoop(l) {
+	}
+}
+
+// serveLoop runs the accept loop for l. If the underlying listener or a conn wrapper
+// panics, the panic is reported and the loop is restarted when WithAutoRecover is used,
+// otherwise l is dropped. It returns true if the loop should be restarted.
+func (m *multiListener) serveLoop(l *managedListener) (restart bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		m.report(l.addr, fmt.Errorf("%w: %v", ErrPanic, r))
+
+		if m.opts.autoRecover && !m.stopped(l) {
+			restart = true
+			return
+		}
+
+		// Mark l first so the other accept routines it stops don't report it as stopped.
+		l.failed.Store(true)
+		if m.drop(l) {
+			m.lifecycle(l.addr, ListenerFailed)
+		} else {
+			l.failed.Store(false)
+		}
+	}()
+
+	var delay time.Duration
+
+	for {
+		if !m.await(l) || !m.coolDown(l) {
+			return false
+		}
+
+		c, err := l.accept(m.stop)
+		l.lastActivity.Store(m.opts.clock.Now().UnixNano())
+		l.idle.Store(false)
+
+		if c == nil && err == nil {
+			err = fmt.Errorf("%w from %s", ErrNilConn, l.addr)
+		}
+		l.count(err)
+		// The slot is taken once a conn is in hand, so a routine never holds one while waiting
+		// in accept on a socket the kernel gives no conns to.
+		if err == nil && !m.acquire(l) {
+			c.Close()
+			return false
+		}
+		// held is set while the slot is not yet given to the conn by wrap.
+		held := err == nil && l.slots != nil
+		if err != nil && (m.exhausted(l, err) || m.backOff(l, err, &delay)) {
+			continue
+		}
+		if err == nil {
+			delay = 0
+		}
+
+		// A conn accepted right before a pause is held until the resume.
+		if !m.stopped(l) && m.await(l) {
+			if err == nil {
+				c, err = m.wrap(l, c)
+				held = false
+				if err == errRejected {
+					continue
+				}
+				if err != nil {
+					// The conn failed its pipeline and is already closed.
+					m.report(l.addr, err)
+					continue
+				}
+			} else {
+				m.report(l.addr, err)
+			}
+
+			if err == nil && m.draining.Load() {
+				m.respond(c)
+				continue
+			}
+
+			select {
+			case <-m.stop:
+			case <-l.quit:
+			case m.inbox(l) <- chanMsg{conn: c, err: err, addr: l.addr}:
+				m.offered(l)
+
 				select {
 				case <-m.stop:
-					return
-				case m.accept <- msg:
+					// Close may have drained the buffer before this send landed.
+					m.drainBuffer()
+					if l.offer != nil {
+						l.discardOffer()
+					}
+					return false
+				default:
 					continue
 				}
 			}
-		}(l)
+		}
+
+		// Nobody will receive a conn accepted during shutdown, so don't leak it.
+		if c != nil {
+			c.Close()
+		}
+		if held {
+			<-l.slots
+		}
+		return false
+	}
+}
+
+// drop removes l after its accept routine died, reporting a failure to close it. The caller
+// must not hold the lock.
+func (m *multiListener) drop(l *managedListener) bool {
+	m.mut.Lock()
+
+	if m.listeners[l.addr] != l {
+		m.mut.Unlock()
+		return false
+	}
+
+	delete(m.listeners, l.addr)
+	m.updateEmpty()
+	close(l.quit)
+	err := l.release()
+	m.mut.Unlock()
+
+	if err != nil {
+		m.report(l.addr, err)
+	}
+	return true
+}
+
+// stopped reports whether l was removed or m was closed.
+func (m *multiListener) stopped(l *managedListener) bool {
+	select {
+	case <-m.stop:
+		return true
+	case <-l.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// logBind logs how long a bind took, warning when it was slower than the configured threshold.
+func (m *multiListener) logBind(network string, address string, d time.Duration, err error) {
+	if m.opts.logger == nil {
+		return
+	}
+
+	attrs := []any{"network", network, "address", address, "duration", d}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	if m.opts.slowBind > 0 && d > m.opts.slowBind {
+		m.opts.logger.Warn("slow bind", append(attrs, "threshold", m.opts.slowBind)...)
+		return
+	}
+
+	m.opts.logger.Debug("bind", attrs...)
+}
+
+// logTolerated warns about the bind failures WithMinListeners let Listen get past.
+func (m *multiListener) logTolerated(failed []error) {
+	if m.opts.logger == nil {
+		return
+	}
+
+	for _, err := range failed {
+		attrs := []any{"error", err}
+
+		var listenErr *ListenError
+		if errors.As(err, &listenErr) {
+			attrs = append(attrs, "network", listenErr.Network, "address", listenErr.Address)
+		}
+
+		m.opts.logger.Warn("bind failed, continuing without it", attrs...)
+	}
+}
+
+// report passes a background error to the error hook and the errors channel, unless
+// WithErrorCoalescing folds it into an earlier one.
+func (m *multiListener) report(addr net.Addr, err error) {
+	if m.opts.coalesce > 0 && !m.coalesce(addr, err) {
+		return
+	}
+	m.emit(addr, err)
+}
+
+// emit passes err to the error hook and the errors channel.
+func (m *multiListener) emit(addr net.Addr, err error) {
+	if m.opts.errorHook != nil {
+		m.opts.errorHook(addr, err)
+	}
+
+	m.errMut.Lock()
+	defer m.errMut.Unlock()
+
+	if m.errs == nil || m.errsClosed {
+		return
+	}
+
+	select {
+	case m.errs <- ListenerError{Addr: addr, Err: err}:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// run starts the routines that watch over the whole multilistener.
+func (m *multiListener) run() {
+	if m.opts.watchdog > 0 {
+		m.goRoutine(func() { m.watch(m.opts.watchdog) })
+	}
+
+	if m.opts.lifetime != nil {
+		m.goRoutine(func() {
+			m.closeOnDone(m.opts.lifetime.Done(), func() error {
+				return fmt.Errorf("%w: %w", ErrLifetimeDone, context.Cause(m.opts.lifetime))
+			})
+		})
+	}
+
+	if m.opts.stopChan != nil {
+		m.goRoutine(func() {
+			m.closeOnDone(m.opts.stopChan, func() error { return ErrStopChannelClosed })
+		})
+	}
+
+}
+
+// closeOnDone closes the multilistener for the reason returned by reason once done is closed.
+// It returns early if the multilistener is closed first. Nobody is around to receive the
+// close error, so it goes to the error hook instead.
+func (m *multiListener) closeOnDone(done <-chan struct{}, reason func() error) {
+	select {
+	case <-m.stop:
+	case <-done:
+		err := m.close(m.opts.closeConns, reason())
+		if err != nil && err != ErrClosed {
+			m.report(m, err)
+		}
+	}
+}
+
+// newMultiListener creates an empty multilistener with opts applied.
+func newMultiListener(opts ...Option) (*multiListener, error) {
+	m := &multiListener{
+		mut:       &sync.RWMutex{},
+		listeners: map[net.Addr]*managedListener{},
+		opts:      newOptions(opts...),
+		ctx:       context.Background(),
+		routines:  &sync.WaitGroup{},
+		connMut:   &sync.Mutex{},
+		conns:     map[*trackedConn]struct{}{},
+		errMut:    &sync.Mutex{},
+		coalesced: map[net.Addr]*coalescedError{},
+		gate:      make(chan struct{}),
+		readyMut:  &sync.Mutex{},
+	}
+	close(m.gate)
+	m.deadline = newDeadline(m.opts.clock)
+	if m.opts.baseContext != nil {
+		m.ctx = m.opts.baseContext
+	}
+	m.life, m.cancel = context.WithCancelCause(context.Background())
+	m.stop = m.life.Done()
+
+	err := m.opts.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	m.accept = make(chan chanMsg, m.opts.acceptBuffer)
+	if m.opts.weights != nil {
+		m.wake = make(chan struct{}, 1)
+	}
+	m.empty = make(chan struct{})
+	m.updateEmpty()
+
+	return m, nil
+}
+
+// Listen listens on multiple network->[]address pairs as defined in the map. Any failed bind
+// fails the whole call unless WithMinListeners is used.
+func Listen(listeners map[string][]string, opts ...Option) (net.Listener, error) {
+	m, err := newMultiListener(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkNetworks(listeners)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.listenPairs(pairsOf(listeners), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.handle(), nil
+}
+
+// handle returns the MultiListener handed to callers for m, once it is started.
+func (m *multiListener) handle() *MultiListener {
+	h := &MultiListener{multiListener: m}
+	h.detectLeak()
+	return h
+}
+
+// ListenContext listens like Listen and closes the multilistener, unblocking Accept, once ctx
+// is done, tying its lifetime to a server's run context like WithLifetimeContext. ctx is also
+// the base context WithConnContext derives the context of each conn from, so its values
+// reach every conn.
+func ListenContext(ctx context.Context, listeners map[string][]string, opts ...Option) (*MultiListener, error) {
+	m, err := Listen(listeners, append(opts[:len(opts):len(opts)], WithLifetimeContext(ctx), withBaseContext(ctx))...)
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MultiListener), nil
+}
+
+// listenPairs binds every pair, calling configure with the index of each pair that bound,
+// and starts the multilistener. On failure everything that bound is closed again, along with
+// the listeners registered up front.
+func (m *multiListener) listenPairs(pairs []pair, configure func(int, *managedListener)) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if !m.opts.reusePort {
+		err := checkDualStack(pairs)
+		if err != nil {
+			m.unbind()
+			return err
+		}
+	}
+
+	m.requested = slices.Clone(pairs)
+	failed := []error{}
+	need := m.opts.minBound()
+	// Listeners registered up front, like activated sockets, count as bound.
+	bound := len(m.listeners)
+
+	for i, p := range pairs {
+		ls, err := m.bindPair(p)
+		if err != nil {
+			if need == 0 {
+				m.unbind()
+				return err
+			}
+			failed = append(failed, err)
+			continue
+		}
+		bound++
+
+		if configure != nil {
+			configure(i, ls[0])
+		}
+		// Sockets of one pair share its settings, including a single MaxConns pool.
+		for _, l := range ls[1:] {
+			l.keepSettings(ls[0])
+		}
+	}
+
+	if bound < need {
+		m.unbind()
+		return &MinListenersError{Bound: bound, Min: need, Failed: failed}
+	}
+	m.bindErrs = failed
+	m.logTolerated(failed)
+
+	for _, l := range m.listeners {
+		m.start(l)
 	}
 
+	m.run()
+
+	return nil
+}
+
+// New multiplexes listeners that are already bound. By default the multilistener takes
+// ownership of them, so they are closed along with it, unless WithoutOwnership is used.
+func New(listeners []net.Listener, opts ...Option) (*MultiListener, error) {
+	m, err := newFromListeners(listeners, true, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return m.handle(), nil
+}
+
+// Combine multiplexes arbitrary listeners, including other MultiListeners, into one.
+// Closing the combined listener stops accepting from the children but does not close
+// them; use New to hand over ownership instead. Child MultiListeners keep all of their
+// conns, while conns other children hand over after the combined listener is closed are closed.
+func Combine(listeners ...net.Listener) net.Listener {
+	m, _ := newFromListeners(listeners, false)
+	return m.handle()
+}
+
+// newFromListeners multiplexes pre-bound listeners.
+func newFromListeners(listeners []net.Listener, owned bool, opts ...Option) (*multiListener, error) {
+	m, err := newMultiListener(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	for _, nL := range listeners {
+		m.register(nL, nL.Addr().Network(), nL.Addr().String(), owned && !m.opts.disowned)
+	}
+
+	for _, l := range m.listeners {
+		m.start(l)
+	}
+
+	m.run()
+
 	return m, nil
 }
 
@@ -1,13 +1,61 @@
 package multilistener
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 )
 
+// fakeListener is a listener whose accept results are scripted by the test.
+type fakeListener struct {
+	accept func() (net.Conn, error)
+	closed chan struct{}
+	once   sync.Once
+}
+
+// newFakeListener creates a fake listener that calls accept until it is closed.
+func newFakeListener(accept func() (net.Conn, error)) *fakeListener {
+	return &fakeListener{accept: accept, closed: make(chan struct{})}
+}
+
+// Accept implements net.Listener.
+func (f *fakeListener) Accept() (net.Conn, error) {
+	select {
+	case <-f.closed:
+		return nil, net.ErrClosed
+	default:
+		return f.accept()
+	}
+}
+
+// Close implements net.Listener.
+func (f *fakeListener) Close() error {
+	f.once.Do(func() {
+		close(f.closed)
+	})
+	return nil
+}
+
+// Addr implements net.Listener.
+func (f *fakeListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+}
+
 // TestMultiListen tests the initial listener.
 func TestMultiListen(t *testing.T) {
 	m, err := Listen(map[string][]string{
@@ -51,6 +99,48 @@ func TestMultiListenAddr(t *testing.T) {
 	})
 }
 
+// TestWithAddrSeparator tests joining the listener networks and addresses with a custom separator.
+func TestWithAddrSeparator(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:8080"},
+		"tcp6": {"[::1]:8080"},
+	}, WithAddrSeparator(","))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	if network := m.Addr().Network(); network != "tcp,tcp" {
+		t.Error("network should use the separator", network)
+	}
+
+	if address := m.Addr().String(); address != "127.0.0.1:8080,[::1]:8080" && address != "[::1]:8080,127.0.0.1:8080" {
+		t.Error("address should use the separator", address)
+	}
+}
+
+// TestNetworks tests that Networks returns each network once.
+func TestNetworks(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0", "127.0.0.1:0"},
+		"tcp6": {"[::1]:0"},
+		"unix": {filepath.Join(t.TempDir(), "networks.sock")},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	networks := m.(*MultiListener).Networks()
+	if !slices.Equal(networks, []string{"tcp", "unix"}) {
+		t.Error("unexpected networks", networks)
+	}
+
+	if strings.Count(m.Addr().Network(), "tcp") != 3 {
+		t.Error("Network should keep an entry per listener", m.Addr().Network())
+	}
+}
+
 // TestMultiListenAddresses listens on multiple interfaces and gets a list of listener addresses.
 func TestMultiListenAddresses(t *testing.T) {
 	m, err := Listen(map[string][]string{
@@ -106,6 +196,33 @@ func TestMultiListenMultipleClose(t *testing.T) {
 	})
 }
 
+// TestCloseOnce tests that CloseOnce ignores repeated closes.
+func TestCloseOnce(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	ml := m.(*MultiListener)
+
+	err = ml.CloseOnce()
+	if err != nil {
+		t.Error("error when closing", err)
+	}
+
+	err = ml.CloseOnce()
+	if err != nil {
+		t.Error("closing again should be a no-op", err)
+	}
+
+	err = ml.Close()
+	if !errors.Is(err, ErrClosed) {
+		t.Error("Close should still report repeated closes", err)
+	}
+}
+
 // TestMultiListenCloseError tests how a close error bubbles up.
 func TestMultiListenCloseError(t *testing.T) {
 	m, err := Listen(map[string][]string{
@@ -265,3 +382,1294 @@ func TestErrorOnListen(t *testing.T) {
 		t.Error("no error when using invalid listen type")
 	}
 }
+
+// TestAddRemoveListener tests adding and removing listeners at runtime.
+func TestAddRemoveListener(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Error("error when listening on valid addresses", err)
+	}
+
+	a, ok := m.(*MultiListener)
+	if !ok {
+		t.Fatal("not a multilistener")
+	}
+
+	addr, err := a.AddListener("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error("error when adding a listener", err)
+	}
+
+	if len(a.Addresses()) != 2 {
+		t.Error("added listener should be returned by addresses", a.Addresses())
+	}
+
+	go func() {
+		c, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Error("error connecting to added listener", err)
+			return
+		}
+		c.Close()
+	}()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Error("error accepting from added listener", err)
+	} else {
+		c.Close()
+	}
+
+	err = a.RemoveListener(addr)
+	if err != nil {
+		t.Error("error when removing a listener", err)
+	}
+
+	if len(a.Addresses()) != 1 {
+		t.Error("removed listener should not be returned by addresses", a.Addresses())
+	}
+
+	err = a.RemoveListener(addr)
+	if !errors.Is(err, ErrListenerNotFound) {
+		t.Error("removing twice should return not found", err)
+	}
+
+	_, err = net.Dial(addr.Network(), addr.String())
+	if err == nil {
+		t.Error("removed listener should no longer accept connections")
+	}
+
+	m.Close()
+
+	_, err = a.AddListener("tcp", "127.0.0.1:0")
+	if !errors.Is(err, ErrClosed) {
+		t.Error("adding to a closed listener should error", err)
+	}
+}
+
+// TestRemoveListenerKeepsConns tests that conns already accepted, from the removed listener
+// or any other, keep working while listeners are added and removed.
+func TestRemoveListenerKeepsConns(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	kept := m.Addresses()[0]
+	added, err := m.AddListener("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when adding a listener", err)
+	}
+
+	clients := []net.Conn{}
+	conns := []net.Conn{}
+	for _, addr := range []net.Addr{kept, added} {
+		c, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer c.Close()
+		clients = append(clients, c)
+
+		conn, err := m.Accept()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+
+	if err := m.RemoveListener(added); err != nil {
+		t.Fatal("error when removing a listener", err)
+	}
+	if _, err := m.AddListener("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal("error when adding a listener", err)
+	}
+
+	for i, c := range clients {
+		if _, err := c.Write([]byte("ping")); err != nil {
+			t.Fatal("error when writing", err)
+		}
+
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conns[i], buf); err != nil || string(buf) != "ping" {
+			t.Error("accepted conns should survive listener changes", i, err)
+		}
+	}
+}
+
+// TestAddressesConcurrentAddRemove stresses the address accessors while listeners are added and removed.
+func TestAddressesConcurrentAddRemove(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Error("error when listening on valid addresses", err)
+	}
+
+	a := m.(*MultiListener)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				for _, addr := range a.Addresses() {
+					if addr == nil {
+						t.Error("addresses should never contain nil")
+						return
+					}
+					_ = addr.String()
+				}
+				_ = a.Network()
+				_ = a.String()
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		addr, err := a.AddListener("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Error("error when adding a listener", err)
+			continue
+		}
+
+		err = a.RemoveListener(addr)
+		if err != nil {
+			t.Error("error when removing a listener", err)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+}
+
+// TestWaitReady tests waiting for the accept routines to start.
+func TestWaitReady(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"tcp6": {"[::1]:0"},
+	})
+
+	if err != nil {
+		t.Error("error when listening on valid addresses", err)
+	}
+
+	err = m.(*MultiListener).WaitReady(context.Background())
+	if err != nil {
+		t.Error("error waiting for accept routines", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+}
+
+// TestAcceptTimeout tests that a single accept times out without affecting the next one.
+func TestAcceptTimeout(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Error("error when listening on valid addresses", err)
+	}
+
+	a := m.(*MultiListener)
+
+	_, err = a.AcceptTimeout(10 * time.Millisecond)
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Error("accept should time out", err)
+	}
+
+	addr := a.Addresses()[0]
+	go func() {
+		c, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Error("error connecting to listener", err)
+			return
+		}
+		c.Close()
+	}()
+
+	c, err := a.AcceptTimeout(time.Second)
+	if err != nil {
+		t.Error("error accepting after a timeout", err)
+	} else {
+		c.Close()
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+}
+
+// TestMultiListenInfo tests reporting requested and resolved addresses.
+func TestMultiListenInfo(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Error("error when listening on valid addresses", err)
+	}
+
+	info := m.(*MultiListener).Info()
+	if len(info) != 1 {
+		t.Fatal("there should be info for each listener", info)
+	}
+
+	if info[0].Network != "tcp" || info[0].RequestedAddr != "127.0.0.1:0" || info[0].ResolvedAddr != m.Addr().String() {
+		t.Error("info should contain the requested and resolved addresses", info[0])
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+}
+
+// TestRequested tests that the requested pairs are kept as given and copied on return.
+func TestRequested(t *testing.T) {
+	requested := map[string][]string{
+		"tcp":  {"127.0.0.1:0", "127.0.0.1:0"},
+		"tcp4": {"127.0.0.1:0"},
+	}
+
+	l, err := Listen(requested)
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	got := m.Requested()
+	if len(got) != 2 || !slices.Equal(got["tcp"], requested["tcp"]) || !slices.Equal(got["tcp4"], requested["tcp4"]) {
+		t.Error("requested pairs should be returned as given", got)
+	}
+
+	got["tcp"][0] = "changed"
+	delete(got, "tcp4")
+
+	if got := m.Requested(); got["tcp"][0] != "127.0.0.1:0" || len(got) != 2 {
+		t.Error("requested pairs should be copied", got)
+	}
+
+	n, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		return nil, net.ErrClosed
+	})})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	n.Close()
+
+	if got := n.Requested(); got != nil {
+		t.Error("multilisteners created by New should have no requested pairs", got)
+	}
+}
+
+// TestStats tests that accepts and accept errors are counted per listener.
+func TestStats(t *testing.T) {
+	boom := errors.New("boom")
+
+	var calls atomic.Int64
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		if calls.Add(1)%2 == 0 {
+			return nil, boom
+		}
+		c, s := net.Pipe()
+		c.Close()
+		return s, nil
+	})})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	for i := 0; i < 4; i++ {
+		conn, _ := m.Accept()
+		if conn != nil {
+			conn.Close()
+		}
+	}
+
+	stats := m.Stats()
+	if len(stats) != 1 {
+		t.Fatal("there should be stats for each listener", stats)
+	}
+
+	if s := stats[0]; s.Addr.String() != "127.0.0.1:1" || s.Accepted < 2 || s.Errors < 2 || s.LastError != boom {
+		t.Error("stats should count accepts and errors", s)
+	}
+}
+
+// TestNew tests multiplexing listeners that are already bound.
+func TestNew(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	m, err := New([]net.Listener{l})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	if m.String() != l.Addr().String() {
+		t.Error("multilistener should contain the listener", m.String())
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Error("should not error on close", err)
+	}
+
+	_, err = l.Accept()
+	if !errors.Is(err, net.ErrClosed) {
+		t.Error("owned listeners should be closed along with the multilistener", err)
+	}
+}
+
+// TestCombine tests accepting from several multilisteners at once.
+func TestCombine(t *testing.T) {
+	children := []net.Listener{}
+	for i := 0; i < 2; i++ {
+		m, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0"},
+		})
+		if err != nil {
+			t.Fatal("error when listening on valid addresses", err)
+		}
+
+		t.Cleanup(func() {
+			m.Close()
+		})
+
+		children = append(children, m)
+	}
+
+	c := Combine(children...)
+
+	for _, child := range children {
+		go func(addr net.Addr) {
+			conn, err := net.Dial("tcp", addr.String())
+			if err != nil {
+				t.Error("error connecting to child", err)
+				return
+			}
+			conn.Close()
+		}(child.Addr())
+
+		conn, err := c.Accept()
+		if err != nil {
+			t.Error("error accepting from combined listener", err)
+			continue
+		}
+		conn.Close()
+	}
+
+	err := c.Close()
+	if err != nil {
+		t.Error("should not error on close", err)
+	}
+
+	go func() {
+		conn, err := net.Dial("tcp", children[0].Addr().String())
+		if err != nil {
+			t.Error("child should still be listening", err)
+			return
+		}
+		conn.Close()
+	}()
+
+	conn, err := children[0].Accept()
+	if err != nil {
+		t.Error("child should still accept after the combined listener is closed", err)
+	} else {
+		conn.Close()
+	}
+}
+
+// TestListenCleanupOnFailure tests that earlier binds are closed when a later bind fails.
+func TestListenCleanupOnFailure(t *testing.T) {
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	t.Cleanup(func() {
+		used.Close()
+	})
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	freeAddr := free.Addr().String()
+	free.Close()
+
+	_, err = Listen(map[string][]string{
+		"tcp": {freeAddr, used.Addr().String()},
+	})
+
+	if err == nil {
+		t.Fatal("listening on a used address should fail")
+	}
+
+	l, err := net.Listen("tcp", freeAddr)
+	if err != nil {
+		t.Error("earlier binds should be closed after a failure", err)
+	} else {
+		l.Close()
+	}
+}
+
+// TestListenCleanupOnThirdFailure tests that a failure after binds on other networks closes all
+// of them, removes unix socket files, and names the address that failed.
+func TestListenCleanupOnThirdFailure(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("unixpacket is not supported on", runtime.GOOS)
+	}
+
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	freeAddr := free.Addr().String()
+	free.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cleanup.sock")
+	used := filepath.Join(dir, "used.sock")
+
+	err = os.WriteFile(used, nil, 0o600)
+	if err != nil {
+		t.Fatal("error when creating file", err)
+	}
+
+	// Networks bind in sorted order, so the unixpacket address is the third bind.
+	_, err = Listen(map[string][]string{
+		"tcp":        {freeAddr},
+		"unix":       {path},
+		"unixpacket": {used},
+	})
+
+	var listenErr *ListenError
+	if !errors.As(err, &listenErr) || listenErr.Network != "unixpacket" || listenErr.Address != used {
+		t.Fatal("the error should name the address that failed", err)
+	}
+
+	if !strings.Contains(err.Error(), used) {
+		t.Error("the message should name the address that failed", err)
+	}
+
+	l, err := net.Listen("tcp", freeAddr)
+	if err != nil {
+		t.Error("earlier tcp binds should be closed after a failure", err)
+	} else {
+		l.Close()
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("unix socket files should be removed after a failure", err)
+	}
+}
+
+// TestAcceptN tests batch accepting and partial results when the context ends.
+func TestAcceptN(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error connecting to listener", err)
+		}
+		defer c.Close()
+	}
+
+	a := m.(*MultiListener)
+
+	for _, n := range []int{0, -1} {
+		if conns, err := a.AcceptN(context.Background(), n); conns != nil || err != nil {
+			t.Error("empty batches should return nothing", n, conns, err)
+		}
+	}
+
+	conns, err := a.AcceptN(context.Background(), 2)
+	if err != nil || len(conns) != 2 {
+		t.Error("should accept a full batch", len(conns), err)
+	}
+
+	for _, c := range conns {
+		c.Close()
+	}
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error connecting to listener", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	conns, err = a.AcceptN(ctx, 2)
+	if !errors.Is(err, context.DeadlineExceeded) || len(conns) != 1 {
+		t.Error("should return the partial batch with the context error", len(conns), err)
+	}
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// TestAcceptPanic tests recovering from a panicking listener with and without auto recover.
+func TestAcceptPanic(t *testing.T) {
+	for _, autoRecover := range []bool{true, false} {
+		c, s := net.Pipe()
+		defer c.Close()
+
+		var panicked atomic.Bool
+		l := newFakeListener(func() (net.Conn, error) {
+			if !panicked.Swap(true) {
+				panic("broken listener")
+			}
+			return s, nil
+		})
+
+		errs := make(chan error, 1)
+		opts := []Option{WithErrorHook(func(addr net.Addr, err error) {
+			errs <- err
+		})}
+		if autoRecover {
+			opts = append(opts, WithAutoRecover())
+		}
+
+		m, err := New([]net.Listener{l}, opts...)
+		if err != nil {
+			t.Fatal("error creating multilistener", err)
+		}
+
+		if err := <-errs; !errors.Is(err, ErrPanic) {
+			t.Error("panics should be reported", err)
+		}
+
+		if autoRecover {
+			conn, err := m.AcceptTimeout(time.Second)
+			if err != nil || conn != s {
+				t.Error("recovered listener should keep accepting", err)
+			}
+		} else {
+			_, err := m.AcceptTimeout(50 * time.Millisecond)
+			if err == nil {
+				t.Error("panicked listener should no longer accept")
+			}
+
+			if len(m.Addresses()) != 0 {
+				t.Error("panicked listener should be removed", m.Addresses())
+			}
+		}
+
+		m.Close()
+	}
+}
+
+// TestRebind tests binding a listener's address again, and leaving wildcard binds alone.
+func TestRebind(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0", ":0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	specific, _ := m.FindAddr("tcp", "127.0.0.1:0")
+	wildcard, _ := m.FindAddr("tcp", ":0")
+
+	if err := m.Rebind(wildcard); err != nil {
+		t.Error("rebinding a wildcard bind should be a no-op", err)
+	}
+	if addr, _ := m.FindAddr("tcp", ":0"); addr != wildcard {
+		t.Error("wildcard bind should not be rebound", addr)
+	}
+
+	if err := m.Rebind(specific); err != nil {
+		t.Fatal("error rebinding", err)
+	}
+
+	rebound, ok := m.FindAddr("tcp", "127.0.0.1:0")
+	if !ok || rebound == specific || rebound.String() != specific.String() {
+		t.Error("listener should be bound again to the same address", rebound)
+	}
+
+	c, err := net.Dial("tcp", rebound.String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	conn, addr, err := m.AcceptFrom()
+	if err != nil || addr != rebound {
+		t.Fatal("rebound listener should accept", addr, err)
+	}
+	conn.Close()
+
+	if err := m.Rebind(specific); err != ErrListenerNotFound {
+		t.Error("the old address should be gone", err)
+	}
+}
+
+// TestRebindFailure tests that a listener whose address can not be bound again is removed
+// and the *ListenError returned.
+func TestRebindFailure(t *testing.T) {
+	gone := errors.New("address is gone")
+	var fail atomic.Bool
+
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithControl(func(network string, address string, c syscall.RawConn) error {
+		if fail.Load() {
+			return gone
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	addr := m.Addresses()[0]
+	fail.Store(true)
+
+	err = m.Rebind(addr)
+
+	listenErr, ok := err.(*ListenError)
+	if !ok || !errors.Is(err, gone) || listenErr.Address != addr.String() {
+		t.Fatal("a failed rebind should return the *ListenError", err)
+	}
+
+	if len(m.Addresses()) != 0 {
+		t.Error("the listener should be removed", m.Addresses())
+	}
+
+	if err := m.Rebind(addr); err != ErrListenerNotFound {
+		t.Error("the removed listener should be gone", err)
+	}
+}
+
+// TestReplaceListener tests swapping the listener bound to an address.
+func TestReplaceListener(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	a := m.(*MultiListener)
+	old := a.Addresses()[0]
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	err = a.ReplaceListener(old, l)
+	if err != nil {
+		t.Fatal("error replacing listener", err)
+	}
+
+	addresses := a.Addresses()
+	if len(addresses) != 1 || addresses[0] != l.Addr() {
+		t.Error("replacement should take the place of the old listener", addresses)
+	}
+
+	if info := a.Info(); info[0].RequestedAddr != "127.0.0.1:0" {
+		t.Error("replacement should keep the requested address", info)
+	}
+
+	_, err = net.Dial("tcp", old.String())
+	if err == nil {
+		t.Error("old listener should be closed")
+	}
+
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Error("error connecting to replacement", err)
+			return
+		}
+		c.Close()
+	}()
+
+	c, err := a.AcceptTimeout(time.Second)
+	if err != nil {
+		t.Error("replacement should accept", err)
+	} else {
+		c.Close()
+	}
+
+	err = a.ReplaceListener(old, l)
+	if !errors.Is(err, ErrListenerNotFound) {
+		t.Error("replacing an unknown address should error", err)
+	}
+}
+
+// TestWithPrimaryAddr tests designating a single address for Addr.
+func TestWithPrimaryAddr(t *testing.T) {
+	primary, _ := net.ResolveTCPAddr("tcp", "[::1]:0")
+
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"tcp6": {"[::1]:0"},
+	}, WithPrimaryAddr(primary))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	addr, ok := m.Addr().(*net.TCPAddr)
+	if !ok || addr.IP.To4() != nil || addr.Port == 0 {
+		t.Error("addr should be the bound primary address", m.Addr())
+	}
+
+	if len(m.(*MultiListener).Addresses()) != 2 {
+		t.Error("addresses should still contain every listener")
+	}
+}
+
+// TestAcceptNilConn tests that a nil conn is never returned without an error.
+func TestAcceptNilConn(t *testing.T) {
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		return nil, nil
+	})})
+
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	c, err := m.Accept()
+	if c != nil || !errors.Is(err, ErrNilConn) {
+		t.Error("a nil conn should be turned into an error", c, err)
+	}
+}
+
+// TestFindAddr tests looking up stored addresses by strings.
+func TestFindAddr(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp4": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	a := m.(*MultiListener)
+	stored := a.Addresses()[0]
+	port := strconv.Itoa(stored.(*net.TCPAddr).Port)
+
+	for _, q := range [][2]string{
+		{"tcp4", "127.0.0.1:0"},
+		{"tcp", stored.String()},
+		{"tcp4", "localhost:" + port},
+	} {
+		addr, ok := a.FindAddr(q[0], q[1])
+		if !ok || addr != stored {
+			t.Error("address should be found", q)
+		}
+	}
+
+	if _, ok := a.FindAddr("tcp6", "[::1]:"+port); ok {
+		t.Error("unbound addresses should not be found")
+	}
+
+	addr, _ := a.FindAddr("tcp4", "127.0.0.1:0")
+	err = a.RemoveListener(addr)
+	if err != nil {
+		t.Error("found addresses should be removable", err)
+	}
+}
+
+// TestConcurrentAccept tests that conns are spread over concurrent Accept callers and that
+// every caller returns ErrClosed on Close.
+func TestConcurrentAccept(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	const callers = 4
+	const dials = 40
+
+	var accepted sync.WaitGroup
+	accepted.Add(dials)
+
+	counts := make([]atomic.Int64, callers)
+	errs := make(chan error, callers)
+
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			for {
+				c, err := m.Accept()
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				counts[i].Add(1)
+				c.Close()
+				accepted.Done()
+			}
+		}(i)
+	}
+
+	for i := 0; i < dials; i++ {
+		c, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		c.Close()
+	}
+
+	accepted.Wait()
+
+	for i := range counts {
+		if counts[i].Load() == 0 {
+			t.Error("caller received no conns", i)
+		}
+	}
+
+	m.Close()
+
+	for i := 0; i < callers; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrClosed) {
+				t.Error("caller should return ErrClosed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("caller did not return after close")
+		}
+	}
+}
+
+// TestAcceptFrom tests that conns and errors report the listener they came from.
+func TestAcceptFrom(t *testing.T) {
+	boom := errors.New("boom")
+	fake := newFakeListener(func() (net.Conn, error) {
+		return nil, boom
+	})
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	m, err := New([]net.Listener{fake, tcp})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	_, addr, err := m.AcceptFrom()
+	if !errors.Is(err, boom) || addr.String() != fake.Addr().String() {
+		t.Error("error should come from the failing listener", addr, err)
+	}
+
+	client, err := net.Dial("tcp", tcp.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	for {
+		c, addr, err := m.AcceptFrom()
+		if err != nil {
+			continue
+		}
+
+		if addr.String() != tcp.Addr().String() {
+			t.Error("conn should come from the tcp listener", addr)
+		}
+		c.Close()
+		break
+	}
+
+	m.Close()
+
+	if _, addr, err := m.AcceptFrom(); !errors.Is(err, ErrClosed) || addr != nil {
+		t.Error("closed multilistener should return ErrClosed without an address", addr, err)
+	}
+}
+
+// TestWait tests that every goroutine exits soon after Close.
+func TestWait(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"tcp6": {"[::1]:0"},
+	}, WithListenerWatchdog(time.Hour), WithLifetimeContext(context.Background()))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	ml := m.(*MultiListener)
+	ml.Shards(2)
+
+	waited := make(chan struct{})
+	go func() {
+		ml.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("wait should block until close")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.Close()
+
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("goroutines did not exit after close")
+	}
+}
+
+// TestWaitStopped tests that Wait returns immediately once shutdown is complete or when
+// the multilistener never started.
+func TestWaitStopped(t *testing.T) {
+	var unstarted MultiListener
+	unstarted.Wait()
+
+	m, err := New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		return nil, net.ErrClosed
+	})})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	m.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Wait()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait should return once shutdown is complete")
+	}
+
+	m.Wait()
+}
+
+// TestNoListeners tests Accept once every listener was removed, with and without
+// WithNoListenersError.
+func TestNoListeners(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithNoListenersError())
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	ml := m.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := m.Accept()
+		accepted <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+
+	err = ml.RemoveListener(ml.Addresses()[0])
+	if err != nil {
+		t.Fatal("error removing listener", err)
+	}
+
+	select {
+	case err := <-accepted:
+		if !errors.Is(err, ErrNoListeners) {
+			t.Error("blocked accept should return ErrNoListeners", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked accept was not woken")
+	}
+
+	_, err = ml.AddListener("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error adding listener", err)
+	}
+
+	if _, err := ml.AcceptTimeout(20 * time.Millisecond); errors.Is(err, ErrNoListeners) {
+		t.Error("accept should block again once a listener is added", err)
+	}
+
+	blocking, err := New(nil)
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { blocking.Close() })
+
+	if _, err := blocking.AcceptTimeout(20 * time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("accept should block by default", err)
+	}
+}
+
+// TestAcceptFromWildcard tests that AcceptFrom reports the wildcard listener rather than
+// the concrete local address of the conn.
+func TestAcceptFromWildcard(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp4": {"0.0.0.0:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	ml := m.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	listener := ml.Addresses()[0].(*net.TCPAddr)
+
+	client, err := net.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.Port)))
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	c, addr, err := ml.AcceptFrom()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	if addr.String() != listener.String() {
+		t.Error("AcceptFrom should return the wildcard listener", addr)
+	}
+
+	if c.LocalAddr().String() != client.RemoteAddr().String() || c.LocalAddr().String() == addr.String() {
+		t.Error("the conn should keep its concrete local address", c.LocalAddr())
+	}
+}
+
+// TestWaitingAcceptors tests that callers blocked in Accept are counted.
+func TestWaitingAcceptors(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		go m.Accept()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for m.WaitingAcceptors() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := m.WaitingAcceptors(); n != 3 {
+		t.Error("three callers should be waiting", n)
+	}
+
+	m.Close()
+
+	deadline = time.Now().Add(time.Second)
+	for m.WaitingAcceptors() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := m.WaitingAcceptors(); n != 0 {
+		t.Error("no callers should be waiting after close", n)
+	}
+}
+
+// TestPublishExpvar tests that stats are published as an expvar, once per name.
+func TestPublishExpvar(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithConnTracking())
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	// Expvars stay published, so every run needs its own name.
+	name := fmt.Sprintf("multilistener_test_%p", m)
+
+	if err := m.PublishExpvar(name); err != nil {
+		t.Fatal("error publishing expvar", err)
+	}
+	if err := m.PublishExpvar(name); err != ErrExpvarExists {
+		t.Error("publishing the same name twice should fail", err)
+	}
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer conn.Close()
+
+	var published struct {
+		Listeners map[string]struct {
+			Network  string
+			Accepted int64
+		}
+		OpenConns int `json:"open_conns"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &published); err != nil {
+		t.Fatal("expvar should be JSON", err)
+	}
+
+	if s := published.Listeners[m.Addr().String()]; s.Network != "tcp" || s.Accepted != 1 || published.OpenConns != 1 {
+		t.Error("expvar should reflect the stats", published)
+	}
+}
+
+// TestTryAccept tests polling for conns without blocking.
+func TestTryAccept(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithAcceptBuffer(1))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	if conn, ok, err := m.TryAccept(); ok || conn != nil || err != nil {
+		t.Error("nothing should be ready yet", conn, err)
+	}
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for m.QueueDepth() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	conn, ok, err := m.TryAccept()
+	if !ok || conn == nil || err != nil {
+		t.Fatal("the buffered conn should be ready", err)
+	}
+	conn.Close()
+
+	m.Close()
+
+	if _, ok, err := m.TryAccept(); !ok || err != ErrClosed {
+		t.Error("polling a closed multilistener should return ErrClosed", ok, err)
+	}
+}
+
+// TestDone tests that Done and Context follow the multilistener's lifetime.
+func TestDone(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+
+	child, cancel := context.WithCancel(m.Context())
+	defer cancel()
+
+	select {
+	case <-m.Done():
+		t.Fatal("done should not be closed while open")
+	case <-child.Done():
+		t.Fatal("child contexts should not be canceled while open")
+	default:
+	}
+
+	m.Close()
+
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Fatal("done should be closed by Close")
+	}
+
+	<-child.Done()
+	if cause := context.Cause(child); cause != ErrClosed {
+		t.Error("child contexts should carry the close reason", cause)
+	}
+}
@@ -0,0 +1,80 @@
+// multilistenertest provides helpers for testing code that uses a multilistener
+package multilistenertest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/antoniomika/multilistener"
+)
+
+// ListenEphemeral binds a loopback listener on an ephemeral port, or a socket in a new
+// temporary directory for unix networks, for each network and multiplexes them. It returns
+// the multilistener along with the address to dial for each network, in the order given.
+// With no networks a single tcp listener is bound. Temporary directories are removed when t
+// and its cleanups finish.
+func ListenEphemeral(t testing.TB, networks ...string) (*multilistener.MultiListener, []string, error) {
+	t.Helper()
+
+	if len(networks) == 0 {
+		networks = []string{"tcp"}
+	}
+
+	listeners := []net.Listener{}
+	addresses := []string{}
+
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	for _, network := range networks {
+		address, err := ephemeralAddress(t, network)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		l, err := net.Listen(network, address)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		listeners = append(listeners, l)
+		addresses = append(addresses, l.Addr().String())
+	}
+
+	m, err := multilistener.New(listeners)
+	if err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+
+	return m, addresses, nil
+}
+
+// ephemeralAddress picks an address for network that the kernel or filesystem fills in.
+func ephemeralAddress(t testing.TB, network string) (string, error) {
+	switch network {
+	case "tcp", "tcp4":
+		return "127.0.0.1:0", nil
+	case "tcp6":
+		return "[::1]:0", nil
+	case "unix", "unixpacket":
+		dir, err := os.MkdirTemp("", "multilistenertest")
+		if err != nil {
+			return "", err
+		}
+		// t.TempDir is not used, as paths under it can exceed the unix socket path limit.
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		return filepath.Join(dir, network+".sock"), nil
+	default:
+		return "", fmt.Errorf("%w %q", errors.ErrUnsupported, network)
+	}
+}
@@ -0,0 +1,68 @@
+package multilistenertest
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestListenEphemeral tests that every returned address can be dialed.
+func TestListenEphemeral(t *testing.T) {
+	networks := []string{"tcp", "tcp6", "unix"}
+
+	m, addresses, err := ListenEphemeral(t, networks...)
+	if err != nil {
+		t.Fatal("error when listening on ephemeral addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	if len(addresses) != len(networks) {
+		t.Fatal("there should be an address per network", addresses)
+	}
+
+	for i, address := range addresses {
+		c, err := net.Dial(networks[i], address)
+		if err != nil {
+			t.Error("error connecting to ephemeral address", address, err)
+			continue
+		}
+		c.Close()
+
+		s, err := m.Accept()
+		if err != nil {
+			t.Error("error accepting", err)
+			continue
+		}
+		s.Close()
+	}
+
+	_, _, err = ListenEphemeral(t, "udp")
+	if err == nil {
+		t.Error("unsupported networks should error")
+	}
+}
+
+// TestListenEphemeralCleanup tests that the temporary directories of unix sockets are removed
+// once the test finishes.
+func TestListenEphemeralCleanup(t *testing.T) {
+	var dir string
+
+	t.Run("listen", func(t *testing.T) {
+		m, addresses, err := ListenEphemeral(t, "unix")
+		if err != nil {
+			t.Fatal("error when listening on ephemeral addresses", err)
+		}
+		t.Cleanup(func() { m.Close() })
+
+		dir = filepath.Dir(addresses[0])
+	})
+
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Error("the temporary directory should be removed", dir, err)
+	}
+}
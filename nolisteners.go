@@ -0,0 +1,48 @@
+package multilistener
+
+import "errors"
+
+// ErrNoListeners is returned by Accept when there are no listeners and WithNoListenersError is used.
+var ErrNoListeners = errors.New("no listeners")
+
+// WithNoListenersError makes Accept return ErrNoListeners while the multilistener has no
+// listeners, such as after RemoveListener removed the last one, instead of blocking until
+// AddListener adds one. Accept calls already blocked when the last listener goes are woken.
+// Conns that were accepted before the last listener went are still handed out first.
+func WithNoListenersError() Option {
+	return func(o *options) {
+		o.noListenersErr = true
+	}
+}
+
+// updateEmpty tracks whether the multilistener has no listeners. The caller must hold the lock.
+func (m *multiListener) updateEmpty() {
+	if !m.opts.noListenersErr {
+		return
+	}
+
+	isEmpty := len(m.listeners) == 0
+	if isEmpty == m.isEmpty {
+		return
+	}
+
+	m.isEmpty = isEmpty
+	if isEmpty {
+		close(m.empty)
+	} else {
+		m.empty = make(chan struct{})
+	}
+}
+
+// emptied returns a channel that is closed while the multilistener has no listeners, or nil
+// if WithNoListenersError is not used.
+func (m *multiListener) emptied() <-chan struct{} {
+	if !m.opts.noListenersErr {
+		return nil
+	}
+
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return m.empty
+}
@@ -0,0 +1,404 @@
+package multilistener
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Option configures a MultiListener.
+type Option func(*options)
+
+type options struct {
+	reusePort   bool
+	disowned    bool
+	tlsDefault  *tls.Config
+	tlsConfigs  map[string]*tls.Config
+	errorHook   func(net.Addr, error)
+	watchdog    time.Duration
+	connContext func(context.Context, net.Addr) context.Context
+	closeConns  bool
+	pauseErr    bool
+	pipeline    []func(net.Conn) (net.Conn, error)
+	autoRecover bool
+	logger      *slog.Logger
+	slowBind    time.Duration
+	primary     net.Addr
+	fastOpen    int
+
+	acceptBuffer     int
+	acceptWorkers    int
+	backlog          int
+	keepAlive        time.Duration
+	reusePortSockets int
+	userControl      func(string, string, syscall.RawConn) error
+	noLinkLocal      bool
+	firstByte        func(net.Addr, time.Duration)
+	minListeners     int
+	partialFailure   bool
+	noListenersErr   bool
+	lifetime         context.Context
+	baseContext      context.Context
+	addrSeparator    string
+	stopChan         <-chan struct{}
+
+	tlsHandshakeTimeout time.Duration
+	coalesce            time.Duration
+	tap                 *tap
+	acceptFilter        func(net.Conn) (net.Conn, error)
+	drainResponder      func(net.Conn)
+	lifecycleHook       func(net.Addr, LifecycleEvent)
+	weights             map[net.Addr]int
+	fdCooldown          time.Duration
+	backoffMax          time.Duration
+	leakDetector        bool
+	proxyAll            bool
+	proxyAddrs          map[string]bool
+	proxyTimeout        time.Duration
+
+	connTracking bool
+	forceClose   bool
+
+	clock clock
+
+	unixMode     os.FileMode
+	unixModeSet  bool
+	unixUID      int
+	unixGID      int
+	unixOwnerSet bool
+	unixStale    bool
+
+	readBuffers  map[string]int
+	writeBuffers map[string]int
+}
+
+// WithReusePort sets SO_REUSEPORT on each TCP socket before it is bound so that multiple
+// processes can listen on the same address and have the kernel balance connections between them.
+// Listen returns ErrReusePortUnsupported on platforms without SO_REUSEPORT.
+func WithReusePort() Option {
+	return func(o *options) {
+		o.reusePort = true
+	}
+}
+
+// WithReusePortSockets binds each TCP address n times with SO_REUSEPORT, implying
+// WithReusePort, so the kernel balances new conns across n sockets that each have their own
+// accept routine, for servers with high connection rates. For ":0" every socket joins the
+// port picked for the first. The sockets show up as separate listeners with the same address
+// in Addresses and Info.
+func WithReusePortSockets(n int) Option {
+	return func(o *options) {
+		o.reusePort = true
+		o.reusePortSockets = n
+	}
+}
+
+// WithTCPFastOpen enables TCP Fast Open on each TCP listener with a queue of qlen pending
+// fast open requests. Non-TCP listeners are unaffected. TFO also needs kernel support, such as
+// the server bit of net.ipv4.tcp_fastopen on linux. Listen returns ErrFastOpenUnsupported on
+// platforms where it can not be set.
+func WithTCPFastOpen(qlen int) Option {
+	return func(o *options) {
+		o.fastOpen = qlen
+	}
+}
+
+// WithMinListeners makes Listen tolerate failed binds as long as at least k listeners bound.
+// Below k, including when fewer than k addresses were given, Listen closes what did bind and
+// returns a *MinListenersError listing the failures. Tolerated failures are logged as warnings
+// through WithLogger, left out of Info and returned by BindErrors.
+func WithMinListeners(k int) Option {
+	return func(o *options) {
+		o.minListeners = k
+	}
+}
+
+// WithPartialFailure makes Listen succeed as long as any of its addresses bound, such as on
+// a multi-homed host with an interface down, like WithMinListeners(1). Along with
+// WithMinListeners, in any order, the larger of the two minimums applies. The addresses that
+// failed are returned by BindErrors.
+func WithPartialFailure() Option {
+	return func(o *options) {
+		o.partialFailure = true
+	}
+}
+
+// minBound returns how many listeners must bind when failed binds are tolerated, or 0 when
+// they are not.
+func (o options) minBound() int {
+	if o.partialFailure {
+		return max(o.minListeners, 1)
+	}
+	return max(o.minListeners, 0)
+}
+
+// WithFirstByteObserver calls fn with the listener address and the time from accept to the
+// first byte read, once per conn. Bytes are counted before TLS, so for TLS listeners this
+// measures the arrival of the ClientHello. Conns that never read data are not observed.
+func WithFirstByteObserver(fn func(addr net.Addr, d time.Duration)) Option {
+	return func(o *options) {
+		o.firstByte = fn
+	}
+}
+
+// WithLifetimeContext closes the multilistener, as if by Close, once ctx is done.
+func WithLifetimeContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.lifetime = ctx
+	}
+}
+
+// withBaseContext replaces context.Background as the base context of WithConnContext.
+func withBaseContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.baseContext = ctx
+	}
+}
+
+// WithStopChannel closes the multilistener, as if by Close, once stop is closed, for shutdown
+// plumbing built on channels rather than contexts. Calling Close as well is safe.
+func WithStopChannel(stop <-chan struct{}) Option {
+	return func(o *options) {
+		o.stopChan = stop
+	}
+}
+
+// WithBacklog sets the size of the kernel accept queue of each TCP listener, which is
+// otherwise taken from the system default, so bursts of new conns are queued instead of
+// refused. The kernel may cap n, such as at net.core.somaxconn on linux. It is only supported
+// on linux; elsewhere it is ignored with a warning through WithLogger.
+func WithBacklog(n int) Option {
+	return func(o *options) {
+		o.backlog = n
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period of conns accepted from the TCP listeners bound
+// by the package, which is otherwise the 15 second default of the net package. A negative d
+// disables keep-alives. Listeners handed to New keep their own settings.
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = d
+	}
+}
+
+// WithControl calls control on each socket the package binds, before it is bound, like
+// net.ListenConfig.Control, so callers can set socket options such as SO_REUSEADDR. It runs
+// after the options set by WithReusePort and WithTCPFastOpen, and an error fails the bind.
+// Networks added with RegisterNetwork bind on their own and are unaffected.
+func WithControl(control func(network string, address string, c syscall.RawConn) error) Option {
+	return func(o *options) {
+		o.userControl = control
+	}
+}
+
+// WithAcceptWorkers runs n accept routines per listener instead of one, for listeners whose
+// Accept is the bottleneck under connection storms, such as ones doing work in Accept. Conns
+// from the same listener are then no longer handed out in accept order.
+func WithAcceptWorkers(n int) Option {
+	return func(o *options) {
+		o.acceptWorkers = n
+	}
+}
+
+// WithAcceptBuffer lets up to n accepted conns queue up for Accept, so accept routines keep
+// accepting while handlers are busy. Conns still in the buffer on Close are closed. Listen
+// returns ErrInvalidOption for a negative n.
+func WithAcceptBuffer(n int) Option {
+	return func(o *options) {
+		o.acceptBuffer = n
+	}
+}
+
+// WithReadBufferSize sets the socket receive buffer of conns accepted from the TCP listeners
+// whose requested or bound address is a key of sizes. Other listeners are unaffected.
+func WithReadBufferSize(sizes map[string]int) Option {
+	return func(o *options) {
+		o.readBuffers = sizes
+	}
+}
+
+// WithWriteBufferSize sets the socket send buffer of conns accepted from the TCP listeners
+// whose requested or bound address is a key of sizes. Other listeners are unaffected.
+func WithWriteBufferSize(sizes map[string]int) Option {
+	return func(o *options) {
+		o.writeBuffers = sizes
+	}
+}
+
+// bufferSize looks up the buffer size configured for l in sizes.
+func (o options) bufferSize(sizes map[string]int, l *managedListener) (int, bool) {
+	if size, ok := sizes[l.address]; ok {
+		return size, true
+	}
+	size, ok := sizes[l.addr.String()]
+	return size, ok
+}
+
+// WithoutOwnership leaves listeners handed to New open when the multilistener is closed
+// or they are removed, so their lifecycle stays with the caller. Close then only stops the
+// accept routines. Listeners bound by the package itself are always closed.
+func WithoutOwnership() Option {
+	return func(o *options) {
+		o.disowned = true
+	}
+}
+
+// WithErrorHook calls hook with errors observed in the background, such as accept
+// errors, along with the address of the listener they came from. The hook is called
+// from accept routines, so it should not block.
+func WithErrorHook(hook func(addr net.Addr, err error)) Option {
+	return func(o *options) {
+		o.errorHook = hook
+	}
+}
+
+// WithListenerWatchdog reports ErrNoActivity through the error hook for any listener whose
+// accept has not returned anything for d. This is informational only, since an idle
+// listener can not be told apart from a dead one.
+func WithListenerWatchdog(d time.Duration) Option {
+	return func(o *options) {
+		o.watchdog = d
+	}
+}
+
+// WithConnContext derives the context of each accepted conn from the multilistener's base
+// context, which is the ctx of ListenContext or else context.Background, and the address of
+// the listener that accepted it, like http.Server.ConnContext. The context can be retrieved
+// with ConnContext. Conns are wrapped below TLS, so TLS listeners still yield *tls.Conn.
+func WithConnContext(fn func(ctx context.Context, addr net.Addr) context.Context) Option {
+	return func(o *options) {
+		o.connContext = fn
+	}
+}
+
+// WithCloseConnsOnShutdown controls what happens to accepted conns when the multilistener
+// is closed. When enabled, conns are tracked and Close closes every one that is still open.
+// When disabled, which is the default, they are left for their handlers to finish.
+func WithCloseConnsOnShutdown(enabled bool) Option {
+	return func(o *options) {
+		o.closeConns = enabled
+	}
+}
+
+// WithPauseError makes Accept and its variants return ErrPaused while the multilistener is
+// paused instead of blocking until it is resumed.
+func WithPauseError() Option {
+	return func(o *options) {
+		o.pauseErr = true
+	}
+}
+
+// WithConnPipeline runs each accepted conn through stages in order, each receiving the conn
+// returned by the previous one. Stages run after the built in wrappers, so on a TLS listener
+// the first stage receives the *tls.Conn. If a stage returns an error the conn is closed,
+// skipped and the error is reported through the error hook. Stages run in the accept
+// routine of the listener, so slow stages hold up that listener.
+func WithConnPipeline(stages []func(net.Conn) (net.Conn, error)) Option {
+	return func(o *options) {
+		o.pipeline = append(o.pipeline, stages...)
+	}
+}
+
+// WithAutoRecover restarts the accept routine of a listener after it recovers from a panic
+// in the underlying listener or a conn wrapper. Without it the listener is removed. Either
+// way the panic is reported as ErrPanic through the error hook.
+func WithAutoRecover() Option {
+	return func(o *options) {
+		o.autoRecover = true
+	}
+}
+
+// WithLogger logs what the multilistener does to logger. Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithSlowBindThreshold logs a warning through the logger for every bind that takes longer
+// than d. Every bind is timed and logged at debug level either way.
+func WithSlowBindThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.slowBind = d
+	}
+}
+
+// WithPrimaryAddr makes Addr return the single bound listener matching addr, for code that
+// expects a listener to have one address. addr is matched on its network and on either the
+// bound or the requested address, so "127.0.0.1:0" designates whatever port was picked.
+// Addresses still returns every address.
+func WithPrimaryAddr(addr net.Addr) Option {
+	return func(o *options) {
+		o.primary = addr
+	}
+}
+
+// WithAddrSeparator joins the entries of Network and String with sep instead of ";", for log
+// and metric pipelines that treat ";" specially.
+func WithAddrSeparator(sep string) Option {
+	return func(o *options) {
+		o.addrSeparator = sep
+	}
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts ...Option) options {
+	o := options{clock: realClock{}, addrSeparator: ";"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// tracking reports whether accepted conns need to be tracked.
+func (o options) tracking() bool {
+	return o.closeConns || o.connTracking || o.forceClose
+}
+
+// validate reports options that can not be honored on this platform.
+func (o options) validate() error {
+	if o.reusePort && !reusePortSupported {
+		return ErrReusePortUnsupported
+	}
+	if o.fastOpen > 0 && !fastOpenSupported {
+		return ErrFastOpenUnsupported
+	}
+	if o.acceptBuffer < 0 {
+		return fmt.Errorf("%w: negative accept buffer %d", ErrInvalidOption, o.acceptBuffer)
+	}
+	return nil
+}
+
+// control sets the configured socket options on a socket before it is bound.
+// The WithControl func runs last, on every network.
+func (o options) control(network string, address string, c syscall.RawConn) error {
+	if strings.HasPrefix(network, "tcp") {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			if o.reusePort {
+				sockErr = setReusePort(fd)
+			}
+			if sockErr == nil && o.fastOpen > 0 {
+				sockErr = setFastOpen(fd, o.fastOpen)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if sockErr != nil {
+			return sockErr
+		}
+	}
+
+	if o.userControl != nil {
+		return o.userControl(network, address, c)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package multilistener
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestWithBufferSize tests that buffer sizes are applied to matching listeners only.
+func TestWithBufferSize(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0", "127.0.0.2:0"},
+	}, WithReadBufferSize(map[string]int{"127.0.0.1:0": 8192}), WithWriteBufferSize(map[string]int{"127.0.0.1:0": 8192}))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	for _, addr := range m.(*MultiListener).Addresses() {
+		client, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal("error connecting to listener", err)
+		}
+		defer client.Close()
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error accepting from listener", err)
+		}
+		defer c.Close()
+
+		raw, err := c.(*net.TCPConn).SyscallConn()
+		if err != nil {
+			t.Fatal("error getting raw conn", err)
+		}
+
+		var rcv, snd int
+		raw.Control(func(fd uintptr) {
+			rcv, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+			snd, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+		})
+
+		// linux doubles the requested size to leave room for bookkeeping.
+		configured := c.LocalAddr().(*net.TCPAddr).IP.Equal(net.IPv4(127, 0, 0, 1))
+		if configured != (rcv == 16384 && snd == 16384) {
+			t.Error("buffer sizes should only be applied to matching listeners", c.LocalAddr(), rcv, snd)
+		}
+	}
+}
+
+// TestWithBacklog tests that a small backlog makes the kernel stop queueing conns while
+// nothing accepts them.
+func TestWithBacklog(t *testing.T) {
+	queued := func(opts ...Option) int {
+		m, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0"},
+		}, opts...)
+		if err != nil {
+			t.Fatal("error when listening", err)
+		}
+		defer m.Close()
+
+		m.(*MultiListener).Pause()
+
+		n := 0
+		for i := 0; i < 10; i++ {
+			c, err := net.DialTimeout("tcp", m.Addr().String(), 100*time.Millisecond)
+			if err != nil {
+				continue
+			}
+			defer c.Close()
+			n++
+		}
+		return n
+	}
+
+	if n := queued(); n != 10 {
+		t.Error("the default backlog should queue every conn", n)
+	}
+
+	if n := queued(WithBacklog(1)); n >= 10 {
+		t.Error("a backlog of 1 should refuse some conns", n)
+	}
+}
+
+// TestWithKeepAlive tests that the keep-alive period is applied to accepted conns.
+func TestWithKeepAlive(t *testing.T) {
+	keepAlive := func(d time.Duration) (int, int) {
+		m, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0"},
+		}, WithKeepAlive(d))
+		if err != nil {
+			t.Fatal("error when listening on valid addresses", err)
+		}
+		defer m.Close()
+
+		client, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error connecting to listener", err)
+		}
+		defer client.Close()
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error accepting from listener", err)
+		}
+		defer c.Close()
+
+		raw, err := c.(*net.TCPConn).SyscallConn()
+		if err != nil {
+			t.Fatal("error getting raw conn", err)
+		}
+
+		var enabled, idle int
+		raw.Control(func(fd uintptr) {
+			enabled, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+			idle, _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+		})
+		return enabled, idle
+	}
+
+	if enabled, idle := keepAlive(42 * time.Second); enabled == 0 || idle != 42 {
+		t.Error("keep-alive period should be applied", enabled, idle)
+	}
+
+	if enabled, _ := keepAlive(-1); enabled != 0 {
+		t.Error("a negative period should disable keep-alives", enabled)
+	}
+}
@@ -0,0 +1,971 @@
+package multilistener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestWithReusePort tests binding the same address twice with SO_REUSEPORT.
+func TestWithReusePort(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithReusePort())
+
+	if !reusePortSupported {
+		if !errors.Is(err, ErrReusePortUnsupported) {
+			t.Error("unsupported platforms should error on listen", err)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Fatal("error when listening with reuse port", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	address := m.(*MultiListener).Addresses()[0].String()
+
+	s, err := Listen(map[string][]string{
+		"tcp": {address},
+	}, WithReusePort())
+
+	if err != nil {
+		t.Fatal("error when listening twice with reuse port", err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	_, err = Listen(map[string][]string{
+		"tcp": {address},
+	})
+
+	if err == nil {
+		t.Error("listening without reuse port should fail")
+	}
+}
+
+// TestWithReusePortSockets tests binding several sockets on one port that all accept.
+func TestWithReusePortSockets(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("SO_REUSEPORT is not supported on this platform")
+	}
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithReusePortSockets(3))
+	if err != nil {
+		t.Fatal("error when listening with reuse port sockets", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	addrs := m.(*MultiListener).Addresses()
+	if len(addrs) != 3 {
+		t.Fatal("each socket should be a listener", addrs)
+	}
+
+	for _, addr := range addrs[1:] {
+		if addr.String() != addrs[0].String() {
+			t.Error("the sockets should share the port picked for the first", addrs)
+		}
+	}
+
+	for _, info := range m.(*MultiListener).Info() {
+		if info.RequestedAddr != "127.0.0.1:0" {
+			t.Error("the sockets should keep the requested address", info)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		c, err := net.Dial("tcp", addrs[0].String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		c.Close()
+
+		conn, err := m.Accept()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		conn.Close()
+	}
+}
+
+// TestWithoutOwnership tests that disowned listeners stay open after Close.
+func TestWithoutOwnership(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	m, err := New([]net.Listener{l}, WithoutOwnership())
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Error("should not error on close", err)
+	}
+
+	_, err = m.Accept()
+	if !errors.Is(err, ErrClosed) {
+		t.Error("closed multilistener should not accept", err)
+	}
+
+	// The stopped accept routine may still take one conn before it exits.
+	dialed := make(chan struct{})
+	go func() {
+		defer close(dialed)
+		for i := 0; i < 2; i++ {
+			c, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Error("disowned listener should still be open", err)
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Error("disowned listener should still accept", err)
+	} else {
+		c.Close()
+	}
+
+	<-dialed
+}
+
+// TestWithSlowBindThreshold tests that slow binds are logged as warnings.
+func TestWithSlowBindThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithLogger(logger), WithSlowBindThreshold(time.Nanosecond))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	out := buf.String()
+	if !strings.Contains(out, "level=WARN msg=\"slow bind\"") || !strings.Contains(out, "address=127.0.0.1:0") {
+		t.Error("slow binds should be logged as warnings", out)
+	}
+}
+
+// TestWithTCPFastOpen tests enabling TCP Fast Open on TCP listeners only.
+func TestWithTCPFastOpen(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"unix": {filepath.Join(t.TempDir(), "fastopen.sock")},
+	}, WithTCPFastOpen(16))
+
+	if !fastOpenSupported {
+		if !errors.Is(err, ErrFastOpenUnsupported) {
+			t.Error("unsupported platforms should error on listen", err)
+		}
+		return
+	}
+
+	if err != nil {
+		t.Fatal("error when listening with fast open", err)
+	}
+
+	m.Close()
+}
+
+// TestWithAcceptBuffer tests that buffered conns from one listener keep their order
+// and that conns left in the buffer are closed along with the multilistener.
+func TestWithAcceptBuffer(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithAcceptBuffer(8))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	addr := m.Addr().String()
+
+	var clients []net.Conn
+	for i := 0; i < 5; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		clients = append(clients, c)
+	}
+
+	for i, client := range clients[:4] {
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+
+		if c.RemoteAddr().String() != client.LocalAddr().String() {
+			t.Errorf("conn %d came from %s, expected %s", i, c.RemoteAddr(), client.LocalAddr())
+		}
+		c.Close()
+	}
+
+	// Give the accept routine time to buffer the last conn before closing.
+	time.Sleep(50 * time.Millisecond)
+	m.Close()
+
+	last := clients[4]
+	last.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := last.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("buffered conn should be closed on shutdown", err)
+	}
+
+	_, err = Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithAcceptBuffer(-1))
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Error("a negative buffer should be rejected", err)
+	}
+}
+
+// TestWithLifetimeContext tests that cancelling the lifetime context closes the multilistener.
+func TestWithLifetimeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithLifetimeContext(ctx))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Accept()
+		done <- err
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Error("accept should return ErrClosed after cancel", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the context did not close the multilistener")
+	}
+
+	if err := m.Close(); !errors.Is(err, ErrClosed) {
+		t.Error("multilistener should already be closed", err)
+	}
+}
+
+// TestListenContext tests that cancelling the context passed to ListenContext closes the multilistener.
+func TestListenContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, err := ListenContext(ctx, map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Accept()
+		done <- err
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != ErrClosed {
+			t.Error("accept should return ErrClosed after cancel", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the context did not close the multilistener")
+	}
+
+	m.Wait()
+	if _, err := net.Dial("tcp", m.Addresses()[0].String()); err == nil {
+		t.Error("listeners should be closed after cancel")
+	}
+}
+
+// TestListenContextConnContext tests that conn contexts derive from the ListenContext ctx.
+func TestListenContextConnContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "base")
+
+	m, err := ListenContext(ctx, map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithConnContext(func(ctx context.Context, addr net.Addr) context.Context {
+		return ctx
+	}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	c, err := net.Dial("tcp", m.Addresses()[0].String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer conn.Close()
+
+	if v := ConnContext(conn).Value(key{}); v != "base" {
+		t.Error("the conn context should carry the values of the ListenContext ctx", v)
+	}
+}
+
+// TestWithLifetimeContextClose tests that closing first stops watching the lifetime context.
+func TestWithLifetimeContextClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithLifetimeContext(context.Background()))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Error("should not error on close", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Error("goroutines leaked after close", before, after)
+	}
+}
+
+// TestWithStopChannel tests that closing the stop channel closes the multilistener, and that
+// closing it after Close is harmless.
+func TestWithStopChannel(t *testing.T) {
+	stop := make(chan struct{})
+
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithStopChannel(stop))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Accept()
+		done <- err
+	}()
+
+	close(stop)
+
+	select {
+	case err := <-done:
+		if err != ErrClosed {
+			t.Error("accept should return ErrClosed once stop is closed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closing the stop channel did not close the multilistener")
+	}
+
+	if err := m.Close(); err != ErrClosed {
+		t.Error("multilistener should already be closed", err)
+	}
+	m.Wait()
+
+	stop = make(chan struct{})
+	l, err = Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithStopChannel(stop))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Error("should not error on close", err)
+	}
+	close(stop)
+	l.(*MultiListener).Wait()
+}
+
+// closeErrListener is a fake listener whose Close fails.
+type closeErrListener struct {
+	*fakeListener
+}
+
+// Close implements net.Listener.
+func (c closeErrListener) Close() error {
+	c.fakeListener.Close()
+	return errCloseFailed
+}
+
+var errCloseFailed = errors.New("close failed")
+
+// TestWithStopChannelCloseError tests that a failed close triggered by the stop channel is
+// reported through the error hook.
+func TestWithStopChannelCloseError(t *testing.T) {
+	stop := make(chan struct{})
+	reported := make(chan error, 1)
+
+	var fake *fakeListener
+	fake = newFakeListener(func() (net.Conn, error) {
+		<-fake.closed
+		return nil, net.ErrClosed
+	})
+
+	m, err := New([]net.Listener{closeErrListener{fake}}, WithStopChannel(stop), WithErrorHook(func(addr net.Addr, err error) {
+		if errors.Is(err, errCloseFailed) {
+			reported <- err
+		}
+	}))
+	if err != nil {
+		t.Fatal("error when creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	close(stop)
+
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Fatal("close error was not reported")
+	}
+}
+
+// TestQueueDepth tests that QueueDepth counts conns waiting in the accept buffer.
+func TestQueueDepth(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithAcceptBuffer(8))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	ml := m.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		t.Cleanup(func() { c.Close() })
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for ml.QueueDepth() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if depth := ml.QueueDepth(); depth != 3 {
+		t.Fatal("unexpected queue depth", depth)
+	}
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	c.Close()
+
+	if depth := ml.QueueDepth(); depth != 2 {
+		t.Error("accept should shrink the queue", depth)
+	}
+}
+
+// TestWithMinListeners tests that Listen tolerates failed binds down to the minimum.
+func TestWithMinListeners(t *testing.T) {
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	defer used.Close()
+
+	listeners := map[string][]string{
+		"tcp":  {"127.0.0.1:0", used.Addr().String()},
+		"tcp6": {"[::1]:0"},
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	m, err := Listen(listeners, WithMinListeners(2), WithLogger(logger))
+	if err != nil {
+		t.Fatal("two of three binds should be enough", err)
+	}
+
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), used.Addr().String()) {
+		t.Error("tolerated failures should be logged as warnings", logs.String())
+	}
+
+	if info := m.(*MultiListener).Info(); len(info) != 2 {
+		t.Error("only the bound listeners should be kept", info)
+	}
+	m.Close()
+
+	_, err = Listen(listeners, WithMinListeners(3))
+
+	var minErr *MinListenersError
+	if !errors.As(err, &minErr) || minErr.Bound != 2 || minErr.Min != 3 || len(minErr.Failed) != 1 {
+		t.Fatal("three binds should be required", err)
+	}
+
+	if !errors.Is(err, ErrTooFewListeners) || !errors.Is(err, ErrAddrInUse) {
+		t.Error("error should unwrap to the sentinel and the bind failure", err)
+	}
+
+	_, err = Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithMinListeners(3))
+	if !errors.As(err, &minErr) || minErr.Bound != 1 || len(minErr.Failed) != 0 {
+		t.Error("fewer addresses than the minimum should fail", err)
+	}
+}
+
+// TestWithPartialFailure tests that Listen keeps what bound and reports what did not.
+func TestWithPartialFailure(t *testing.T) {
+	used, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	defer used.Close()
+
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0", used.Addr().String()},
+	}, WithPartialFailure())
+	if err != nil {
+		t.Fatal("one bound address should be enough", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	if len(m.Addresses()) != 1 {
+		t.Error("only the bound listener should be kept", m.Addresses())
+	}
+
+	bindErrs := m.BindErrors()
+
+	var listenErr *ListenError
+	if len(bindErrs) != 1 || !errors.As(bindErrs[0], &listenErr) || listenErr.Address != used.Addr().String() {
+		t.Error("the failed address should be reported", bindErrs)
+	}
+
+	if err := errors.Join(bindErrs...); !errors.Is(err, ErrAddrInUse) {
+		t.Error("the joined bind errors should unwrap to the failure", err)
+	}
+
+	_, err = Listen(map[string][]string{
+		"tcp": {used.Addr().String()},
+	}, WithPartialFailure())
+
+	var minErr *MinListenersError
+	if !errors.As(err, &minErr) || minErr.Bound != 0 {
+		t.Error("listen should fail when nothing bound", err)
+	}
+
+	for _, opts := range [][]Option{
+		{WithMinListeners(2), WithPartialFailure()},
+		{WithPartialFailure(), WithMinListeners(2)},
+	} {
+		_, err = Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0", used.Addr().String()},
+		}, opts...)
+		if !errors.As(err, &minErr) || minErr.Min != 2 {
+			t.Error("the larger minimum should apply in any order", err)
+		}
+	}
+}
+
+// TestWithAcceptWorkers tests that each listener gets the requested number of accept
+// routines and that all of them stop on Close.
+func TestWithAcceptWorkers(t *testing.T) {
+	var inAccept atomic.Int64
+	release := make(chan struct{})
+
+	fake := newFakeListener(func() (net.Conn, error) {
+		inAccept.Add(1)
+		<-release
+		return nil, net.ErrClosed
+	})
+
+	m, err := New([]net.Listener{fake}, WithAcceptWorkers(3))
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for inAccept.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := inAccept.Load(); n != 3 {
+		t.Error("expected three concurrent accepts", n)
+	}
+
+	m.Close()
+	close(release)
+
+	waited := make(chan struct{})
+	go func() {
+		m.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("accept workers did not exit after close")
+	}
+}
+
+// TestWithListenerLifecycleHook tests that listeners report starting, stopping and failing.
+func TestWithListenerLifecycleHook(t *testing.T) {
+	type event struct {
+		addr  string
+		event LifecycleEvent
+	}
+
+	events := make(chan event, 10)
+	var m *MultiListener
+	hook := func(addr net.Addr, e LifecycleEvent) {
+		if e == ListenerStopped {
+			// The hook must be able to call back into the multilistener.
+			m.Addresses()
+		}
+		events <- event{addr.String(), e}
+	}
+
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0", "127.0.0.1:0"},
+	}, WithAcceptWorkers(2), WithListenerLifecycleHook(hook))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m = l.(*MultiListener)
+
+	addrs := m.Addresses()
+	started := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-events:
+			if e.event != ListenerStarted {
+				t.Error("listeners should report starting first", e)
+			}
+			started[e.addr] = true
+		case <-time.After(time.Second):
+			t.Fatal("listeners did not report starting")
+		}
+	}
+	if len(started) != 2 {
+		t.Error("each listener should report starting once", started)
+	}
+
+	if err := m.RemoveListener(addrs[0]); err != nil {
+		t.Fatal("error removing listener", err)
+	}
+	if e := <-events; e != (event{addrs[0].String(), ListenerStopped}) {
+		t.Error("removed listener should report stopping", e)
+	}
+
+	m.Close()
+	if e := <-events; e != (event{addrs[1].String(), ListenerStopped}) {
+		t.Error("closed listener should report stopping", e)
+	}
+	m.Wait()
+
+	if len(events) != 0 {
+		t.Error("listeners should report stopping once", <-events)
+	}
+
+	failed := make(chan LifecycleEvent, 10)
+	m, err = New([]net.Listener{newFakeListener(func() (net.Conn, error) {
+		panic("broken listener")
+	})}, WithListenerLifecycleHook(func(addr net.Addr, e LifecycleEvent) {
+		failed <- e
+	}))
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	if e := <-failed; e != ListenerStarted {
+		t.Error("listener should report starting", e)
+	}
+	if e := <-failed; e != ListenerFailed {
+		t.Error("panicked listener should report failing", e)
+	}
+
+	m.Close()
+	m.Wait()
+	if len(failed) != 0 {
+		t.Error("failed listener should not report stopping", <-failed)
+	}
+}
+
+// TestWithListenerWeights tests that ready listeners are picked in proportion to their weights.
+func TestWithListenerWeights(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0", "127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	addrs := l.Addr().(*MultiListener).Addresses()
+	l.Close()
+
+	heavy, light := addrs[0], addrs[1]
+
+	l, err = Listen(map[string][]string{
+		"tcp": {heavy.String(), light.String()},
+	}, WithListenerWeights(map[net.Addr]int{heavy: 9}))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+
+	// Queue up conns on both listeners so both are always ready.
+	for i := 0; i < 100; i++ {
+		for _, addr := range addrs {
+			c, err := net.Dial("tcp", addr.String())
+			if err != nil {
+				t.Fatal("error when dialing", err)
+			}
+			defer c.Close()
+		}
+	}
+
+	picked := map[string]int{}
+	for i := 0; i < 100; i++ {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			m.readyMut.Lock()
+			ready := len(m.ready)
+			m.readyMut.Unlock()
+			if ready == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		conn, addr, err := m.AcceptFrom()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		conn.Close()
+		picked[addr.String()]++
+	}
+
+	if picked[heavy.String()] < 75 {
+		t.Error("the heavier listener should be picked more often", picked)
+	}
+
+	m.Close()
+	m.Wait()
+}
+
+// TestWithListenerWeightsRebind tests that a rebound listener keeps the weight configured for
+// its requested address.
+func TestWithListenerWeightsRebind(t *testing.T) {
+	requested := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	l, err := Listen(map[string][]string{
+		"tcp": {requested.String()},
+	}, WithListenerWeights(map[net.Addr]int{requested: 5}))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	if err := m.Rebind(m.Addresses()[0]); err != nil {
+		t.Fatal("error rebinding", err)
+	}
+
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	for _, rebound := range m.listeners {
+		if rebound.weight != 5 || rebound.address != requested.String() {
+			t.Error("the rebound listener should keep its weight and requested address", rebound.weight, rebound.address)
+		}
+	}
+}
+
+// logLines is a writer sending each log line on the channel.
+type logLines chan string
+
+// Write implements io.Writer.
+func (l logLines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+// TestWithLeakDetector tests that a multilistener collected without Close is logged, even
+// while it is still accepting, and a closed one is not.
+func TestWithLeakDetector(t *testing.T) {
+	lines := make(logLines, 10)
+	logger := slog.New(slog.NewTextHandler(lines, nil))
+
+	var leaked *multiListener
+	leak := func(closed bool) {
+		m, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0"},
+		}, WithLeakDetector(), WithLogger(logger))
+		if err != nil {
+			t.Fatal("error when listening on valid addresses", err)
+		}
+
+		if closed {
+			m.Close()
+			return
+		}
+		leaked = m.(*MultiListener).multiListener
+	}
+
+	leak(true)
+	leak(false)
+	// Holding on to the state does not keep the MultiListener itself reachable.
+	t.Cleanup(func() { leaked.Close() })
+
+	var line string
+	deadline := time.Now().Add(5 * time.Second)
+	for line == "" && time.Now().Before(deadline) {
+		runtime.GC()
+
+		select {
+		case line = <-lines:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if !strings.Contains(line, "level=WARN") || !strings.Contains(line, "without being closed") {
+		t.Error("leaks should be logged as warnings", line)
+	}
+
+	runtime.GC()
+	time.Sleep(10 * time.Millisecond)
+
+	if len(lines) != 0 {
+		t.Error("only the leaked multilistener should be logged", <-lines)
+	}
+}
+
+// TestCloseReason tests that the reason for closing is kept.
+func TestCloseReason(t *testing.T) {
+	cause := errors.New("deploy")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	stop := make(chan struct{})
+
+	cases := []struct {
+		opts  []Option
+		close func(m *MultiListener)
+		want  []error
+	}{
+		{nil, func(m *MultiListener) { m.Close() }, []error{ErrClosed}},
+		{[]Option{WithLifetimeContext(ctx)}, func(m *MultiListener) { cancel(cause) }, []error{ErrLifetimeDone, cause}},
+		{[]Option{WithStopChannel(stop)}, func(m *MultiListener) { close(stop) }, []error{ErrStopChannelClosed}},
+	}
+
+	for _, c := range cases {
+		l, err := Listen(map[string][]string{
+			"tcp": {"127.0.0.1:0"},
+		}, c.opts...)
+		if err != nil {
+			t.Fatal("error when listening", err)
+		}
+		m := l.(*MultiListener)
+
+		if err := m.CloseReason(); err != nil {
+			t.Error("open multilisteners should have no close reason", err)
+		}
+
+		c.close(m)
+		m.Wait()
+
+		reason := m.CloseReason()
+		for _, want := range c.want {
+			if !errors.Is(reason, want) {
+				t.Error("unexpected close reason", want, reason)
+			}
+		}
+
+		if err := m.Close(); err != ErrClosed || m.CloseReason() != reason {
+			t.Error("closing again should keep the first reason", err, m.CloseReason())
+		}
+	}
+}
+
+// TestWithControl tests that the control func sees every socket before it is bound and can fail the bind.
+func TestWithControl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	seen := map[string]string{}
+
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"unix": {path},
+	}, WithControl(func(network string, address string, c syscall.RawConn) error {
+		seen[network] = address
+		return c.Control(func(fd uintptr) {})
+	}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	m.Close()
+
+	if seen["tcp4"] != "127.0.0.1:0" || len(seen) != 2 {
+		t.Error("control should be called for each socket", seen)
+	}
+
+	refused := errors.New("refused")
+	_, err = Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithControl(func(string, string, syscall.RawConn) error {
+		return refused
+	}))
+	if !errors.Is(err, refused) {
+		t.Error("a control error should fail the bind", err)
+	}
+}
@@ -0,0 +1,311 @@
+package multilistener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoPacketConn is returned by WriteTo and WriteToLocal when no conn of a MultiPacketConn
+// can reach the address.
+var ErrNoPacketConn = errors.New("no packet conn for address")
+
+// knownPacketNetworks are the networks ListenPacket can bind.
+var knownPacketNetworks = []string{"udp", "udp4", "udp6", "unixgram"}
+
+// maxPacketSize is the largest datagram a MultiPacketConn reads in one go.
+const maxPacketSize = 65535
+
+// MultiPacketConn is the datagram counterpart of MultiListener. It reads from many
+// net.PacketConns, such as udp and unixgram sockets, behind one net.PacketConn. Like
+// MultiListener it also implements net.Addr, listing the address of every conn.
+type MultiPacketConn struct {
+	conns    []net.PacketConn
+	owned    []string
+	packets  chan packetMsg
+	opts     options
+	stop     chan struct{}
+	once     *sync.Once
+	routines *sync.WaitGroup
+	read     *deadline
+}
+
+// packetMsg is a datagram read by one of the conns, along with the conn's address.
+type packetMsg struct {
+	data  []byte
+	addr  net.Addr
+	local net.Addr
+	err   error
+}
+
+// ListenPacket binds every network->[]address pair in the map, with networks among udp,
+// udp4, udp6 and unixgram, and reads from all of them. Any failed bind closes what did bind
+// and returns the *ListenError. unixgram socket files are removed on Close. Options about
+// conns and accepting do not apply; WithControl and WithErrorHook do.
+func ListenPacket(listeners map[string][]string, opts ...Option) (*MultiPacketConn, error) {
+	o := newOptions(opts...)
+
+	unknown := []string{}
+	for network := range listeners {
+		if !slices.Contains(knownPacketNetworks, network) {
+			unknown = append(unknown, network)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &ListenError{
+			Network: strings.Join(unknown, ","),
+			Err:     fmt.Errorf("%w %s, expected one of %s", ErrUnknownNetwork, strings.Join(unknown, ", "), strings.Join(knownPacketNetworks, ", ")),
+		}
+	}
+
+	lc := net.ListenConfig{Control: o.control}
+	conns := []net.PacketConn{}
+	owned := []string{}
+
+	for _, p := range pairsOf(listeners) {
+		c, err := lc.ListenPacket(context.Background(), p.network, p.address)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			for _, path := range owned {
+				os.Remove(path)
+			}
+			return nil, newListenError(p.network, p.address, err)
+		}
+
+		if p.network == "unixgram" && !strings.HasPrefix(p.address, "@") {
+			owned = append(owned, p.address)
+		}
+		conns = append(conns, c)
+	}
+
+	m := newMultiPacketConn(conns, o)
+	m.owned = owned
+
+	return m, nil
+}
+
+// NewPacketConn reads from conns that are already bound. The MultiPacketConn takes ownership
+// of them, so they are closed along with it.
+func NewPacketConn(conns []net.PacketConn, opts ...Option) *MultiPacketConn {
+	return newMultiPacketConn(slices.Clone(conns), newOptions(opts...))
+}
+
+// newMultiPacketConn starts reading from conns.
+func newMultiPacketConn(conns []net.PacketConn, o options) *MultiPacketConn {
+	m := &MultiPacketConn{
+		conns:    conns,
+		packets:  make(chan packetMsg),
+		opts:     o,
+		stop:     make(chan struct{}),
+		once:     &sync.Once{},
+		routines: &sync.WaitGroup{},
+		read:     newDeadline(o.clock),
+	}
+
+	for _, c := range conns {
+		m.routines.Add(1)
+		go m.serve(c)
+	}
+
+	return m
+}
+
+// serve reads datagrams from c until it is closed.
+func (m *MultiPacketConn) serve(c net.PacketConn) {
+	defer m.routines.Done()
+
+	buf := make([]byte, maxPacketSize)
+	local := c.LocalAddr()
+
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil && m.opts.errorHook != nil {
+			m.opts.errorHook(local, err)
+		}
+
+		msg := packetMsg{data: bytes.Clone(buf[:n]), addr: addr, local: local, err: err}
+
+		select {
+		case m.packets <- msg:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// ReadFrom implements net.PacketConn, reading the next datagram from any of the conns.
+func (m *MultiPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, _, err := m.ReadFromLocal(b)
+	return n, addr, err
+}
+
+// ReadFromLocal reads like ReadFrom and also returns the local address of the conn the
+// datagram arrived on, so replies can be sent from it with WriteToLocal. As with udp
+// sockets, a datagram longer than b is truncated.
+func (m *MultiPacketConn) ReadFromLocal(b []byte) (n int, addr net.Addr, local net.Addr, err error) {
+	select {
+	case <-m.stop:
+		return 0, nil, nil, ErrClosed
+	default:
+	}
+
+	select {
+	case msg := <-m.packets:
+		return copy(b, msg.data), msg.addr, msg.local, msg.err
+	case <-m.stop:
+		return 0, nil, nil, ErrClosed
+	case <-m.read.done():
+		return 0, nil, nil, &net.OpError{Op: "read", Net: m.Network(), Addr: m, Err: os.ErrDeadlineExceeded}
+	}
+}
+
+// WriteTo implements net.PacketConn, sending from the first conn that can reach addr: udp
+// addresses go out of a conn of the same IP version, or else of a dual-stack one, and unix
+// addresses out of a unixgram conn.
+func (m *MultiPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c := m.connFor(addr)
+	if c == nil {
+		return 0, &net.OpError{Op: "write", Net: addr.Network(), Addr: addr, Err: ErrNoPacketConn}
+	}
+	return c.WriteTo(b, addr)
+}
+
+// WriteToLocal sends b to addr from the conn with the local address local, such as one
+// returned by ReadFromLocal.
+func (m *MultiPacketConn) WriteToLocal(b []byte, addr net.Addr, local net.Addr) (int, error) {
+	for _, c := range m.conns {
+		if c.LocalAddr().Network() == local.Network() && c.LocalAddr().String() == local.String() {
+			return c.WriteTo(b, addr)
+		}
+	}
+	return 0, &net.OpError{Op: "write", Net: addr.Network(), Source: local, Addr: addr, Err: ErrNoPacketConn}
+}
+
+// connFor picks the conn WriteTo sends to addr from.
+func (m *MultiPacketConn) connFor(addr net.Addr) net.PacketConn {
+	if _, ok := addr.(*net.UnixAddr); ok {
+		for _, c := range m.conns {
+			if _, ok := c.LocalAddr().(*net.UnixAddr); ok {
+				return c
+			}
+		}
+		return nil
+	}
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	ip4 := udpAddr.IP.To4() != nil
+
+	var dualStack net.PacketConn
+	for _, c := range m.conns {
+		local, ok := c.LocalAddr().(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		if (local.IP.To4() != nil) == ip4 {
+			return c
+		}
+		// Only an IPv6 wildcard conn can also reach IPv4 addresses, not the other way around.
+		if dualStack == nil && local.IP.IsUnspecified() && local.IP.To4() == nil {
+			dualStack = c
+		}
+	}
+	return dualStack
+}
+
+// Close implements net.PacketConn, closing every conn and unblocking ReadFrom.
+func (m *MultiPacketConn) Close() error {
+	err := ErrClosed
+
+	m.once.Do(func() {
+		close(m.stop)
+
+		errs := []error{}
+		for _, c := range m.conns {
+			errs = append(errs, c.Close())
+		}
+		for _, path := range m.owned {
+			os.Remove(path)
+		}
+
+		m.routines.Wait()
+		err = errors.Join(errs...)
+	})
+
+	return err
+}
+
+// LocalAddr implements net.PacketConn.
+func (m *MultiPacketConn) LocalAddr() net.Addr {
+	return m
+}
+
+// Addresses returns the local address of every conn.
+func (m *MultiPacketConn) Addresses() []net.Addr {
+	a := make([]net.Addr, 0, len(m.conns))
+	for _, c := range m.conns {
+		a = append(a, c.LocalAddr())
+	}
+	return a
+}
+
+// Network implements net.Addr. It has one entry per conn, lined up with String.
+func (m *MultiPacketConn) Network() string {
+	a := []string{}
+	for _, addr := range m.Addresses() {
+		a = append(a, addr.Network())
+	}
+	return strings.Join(a, m.opts.addrSeparator)
+}
+
+// String implements net.Addr.
+func (m *MultiPacketConn) String() string {
+	a := []string{}
+	for _, addr := range m.Addresses() {
+		a = append(a, addr.String())
+	}
+	return strings.Join(a, m.opts.addrSeparator)
+}
+
+// SetDeadline implements net.PacketConn, setting the read and write deadlines.
+func (m *MultiPacketConn) SetDeadline(t time.Time) error {
+	m.read.set(t)
+	return m.SetWriteDeadline(t)
+}
+
+// SetReadDeadline implements net.PacketConn. It applies to ReadFrom as a whole rather than to
+// a single conn.
+func (m *MultiPacketConn) SetReadDeadline(t time.Time) error {
+	m.read.set(t)
+	return nil
+}
+
+// SetWriteDeadline implements net.PacketConn, setting the write deadline of every conn.
+func (m *MultiPacketConn) SetWriteDeadline(t time.Time) error {
+	errs := []error{}
+	for _, c := range m.conns {
+		errs = append(errs, c.SetWriteDeadline(t))
+	}
+	return errors.Join(errs...)
+}
+
+var _ net.PacketConn = &MultiPacketConn{}
+var _ net.Addr = &MultiPacketConn{}
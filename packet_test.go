@@ -0,0 +1,169 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestListenPacket tests reading from and replying on multiple udp conns.
+func TestListenPacket(t *testing.T) {
+	m, err := ListenPacket(map[string][]string{
+		"udp4": {"127.0.0.1:0"},
+		"udp6": {"[::1]:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	addrs := m.Addresses()
+	if len(addrs) != 2 {
+		t.Fatal("each address should have a conn", addrs)
+	}
+
+	for _, addr := range addrs {
+		c, err := net.Dial("udp", addr.String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer c.Close()
+
+		c.Write([]byte("ping"))
+
+		b := make([]byte, 16)
+		n, from, local, err := m.ReadFromLocal(b)
+		if err != nil || string(b[:n]) != "ping" {
+			t.Fatal("error when reading", string(b[:n]), err)
+		}
+
+		if from.String() != c.LocalAddr().String() || local.String() != addr.String() {
+			t.Error("datagrams should carry their sender and the conn they arrived on", from, local)
+		}
+
+		if _, err := m.WriteTo([]byte("pong"), from); err != nil {
+			t.Fatal("error when replying", err)
+		}
+
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		n, err = c.Read(b)
+		if err != nil || string(b[:n]) != "pong" {
+			t.Error("the reply should come from the conn of the same IP version", string(b[:n]), err)
+		}
+	}
+
+	_, err = m.WriteToLocal([]byte("pong"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1})
+	if !errors.Is(err, ErrNoPacketConn) {
+		t.Error("writing from an unknown local address should fail", err)
+	}
+}
+
+// TestListenPacketWildcard tests that an IPv4 wildcard conn is not picked for IPv6 addresses.
+func TestListenPacketWildcard(t *testing.T) {
+	m, err := ListenPacket(map[string][]string{
+		"udp4": {"0.0.0.0:0"},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	_, err = m.WriteTo([]byte("pong"), &net.UDPAddr{IP: net.IPv6loopback, Port: 1})
+	if !errors.Is(err, ErrNoPacketConn) {
+		t.Error("IPv6 addresses should not go out of an IPv4 wildcard conn", err)
+	}
+}
+
+// TestListenPacketUnixgram tests that unixgram socket files are removed on close.
+func TestListenPacketUnixgram(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
+		t.Skip("unixgram is not supported on", runtime.GOOS)
+	}
+
+	path := filepath.Join(t.TempDir(), "packet.sock")
+
+	m, err := ListenPacket(map[string][]string{
+		"unixgram": {path},
+	})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	c, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	c.Write([]byte("ping"))
+
+	b := make([]byte, 16)
+	n, _, err := m.ReadFrom(b)
+	if err != nil || string(b[:n]) != "ping" {
+		t.Error("error when reading", string(b[:n]), err)
+	}
+
+	m.Close()
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("socket file should be removed on close", err)
+	}
+}
+
+// TestListenPacketUnknownNetwork tests that stream networks are rejected.
+func TestListenPacketUnknownNetwork(t *testing.T) {
+	_, err := ListenPacket(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+	if !errors.Is(err, ErrUnknownNetwork) {
+		t.Error("stream networks should be unknown", err)
+	}
+}
+
+// TestMultiPacketConnDeadline tests that ReadFrom times out once the read deadline passed and
+// that Close unblocks it.
+func TestMultiPacketConnDeadline(t *testing.T) {
+	clock := newFakeClock()
+
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	m := NewPacketConn([]net.PacketConn{c}, withClock(clock))
+	t.Cleanup(func() { m.Close() })
+
+	m.SetReadDeadline(clock.Now().Add(time.Minute))
+
+	read := make(chan error, 1)
+	go func() {
+		_, _, err := m.ReadFrom(make([]byte, 16))
+		read <- err
+	}()
+
+	clock.awaitTimers(t, 1)
+	clock.Advance(time.Minute)
+
+	var netErr net.Error
+	if err := <-read; !errors.Is(err, os.ErrDeadlineExceeded) || !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Error("reads should time out after the deadline", err)
+	}
+
+	m.SetReadDeadline(time.Time{})
+
+	go func() {
+		_, _, err := m.ReadFrom(make([]byte, 16))
+		read <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	m.Close()
+
+	if err := <-read; err != ErrClosed {
+		t.Error("close should unblock reads", err)
+	}
+}
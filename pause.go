@@ -0,0 +1,71 @@
+package multilistener
+
+import "errors"
+
+// ErrPaused is returned by Accept while paused when WithPauseError is used.
+var ErrPaused = errors.New("listener is paused")
+
+// Pause stops handing out new conns while keeping every socket bound. New conns queue
+// in the kernel backlog, and conns that were already accepted or buffered are held until
+// Resume. Accept and its variants block while paused, unless WithPauseError is used, and
+// TryAccept reports nothing ready.
+func (m *multiListener) Pause() {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.paused.Swap(true) {
+		return
+	}
+	m.gate = make(chan struct{})
+}
+
+// Resume starts handing out conns again after Pause or Drain.
+func (m *multiListener) Resume() {
+	m.draining.Store(false)
+
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if !m.paused.Swap(false) {
+		return
+	}
+	close(m.gate)
+}
+
+// Paused reports whether the multilistener is paused.
+func (m *multiListener) Paused() bool {
+	return m.paused.Load()
+}
+
+// resumed returns a channel that is closed while the multilistener is not paused.
+func (m *multiListener) resumed() <-chan struct{} {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	return m.gate
+}
+
+// hold blocks a result received while Pause was being called until Resume or Close, so that
+// it is not handed out during the pause.
+func (m *multiListener) hold() {
+	if !m.paused.Load() {
+		return
+	}
+
+	select {
+	case <-m.resumed():
+	case <-m.stop:
+	}
+}
+
+// await blocks while paused. It returns false if l was removed or m was closed meanwhile.
+func (m *multiListener) await(l *managedListener) bool {
+	select {
+	case <-m.resumed():
+		return true
+	case <-m.stop:
+		return false
+	case <-l.quit:
+		return false
+	}
+}
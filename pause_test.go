@@ -0,0 +1,200 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestPauseResume tests that conns made while paused are delivered after resuming.
+func TestPauseResume(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithPauseError())
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	a := m.(*MultiListener)
+	a.Pause()
+
+	if !a.Paused() {
+		t.Error("listener should report being paused")
+	}
+
+	_, err = a.Accept()
+	if !errors.Is(err, ErrPaused) {
+		t.Error("accept should error while paused", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error connecting while paused", err)
+		}
+		defer c.Close()
+	}
+
+	_, err = a.AcceptTimeout(50 * time.Millisecond)
+	if err == nil {
+		t.Error("no conns should be handed out while paused")
+	}
+
+	a.Resume()
+
+	for i := 0; i < 2; i++ {
+		c, err := a.AcceptTimeout(time.Second)
+		if err != nil {
+			t.Fatal("conns made while paused should be delivered after resuming", err)
+		}
+		c.Close()
+	}
+}
+
+// TestPauseHolds tests that conns already buffered, or received by an Accept that was waiting
+// when Pause was called, are held until Resume.
+func TestPauseHolds(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithAcceptBuffer(4))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := m.Accept()
+		accepted <- conn
+	}()
+
+	for m.waiting.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	m.Pause()
+
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial("tcp", m.Addr().String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer c.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(m.accept) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case conn := <-accepted:
+		conn.Close()
+		t.Fatal("an accept waiting before the pause should not return a conn while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := m.AcceptTimeout(50 * time.Millisecond); err == nil {
+		t.Error("buffered conns should not be handed out while paused")
+	}
+
+	if _, ready, _ := m.TryAccept(); ready {
+		t.Error("nothing should be ready while paused")
+	}
+
+	m.Resume()
+
+	if conn := <-accepted; conn == nil {
+		t.Error("the waiting accept should get a conn after resuming")
+	} else {
+		conn.Close()
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := m.AcceptTimeout(time.Second)
+		if err != nil {
+			t.Fatal("buffered conns should be handed out after resuming", err)
+		}
+		conn.Close()
+	}
+}
+
+// TestPauseErrorVariants tests that the accept variants return ErrPaused with WithPauseError.
+func TestPauseErrorVariants(t *testing.T) {
+	l, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithPauseError())
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	m.Pause()
+
+	if _, err := m.AcceptTimeout(time.Second); !errors.Is(err, ErrPaused) {
+		t.Error("AcceptTimeout should error while paused", err)
+	}
+
+	if _, err := m.AcceptN(context.Background(), 2); !errors.Is(err, ErrPaused) {
+		t.Error("AcceptN should error while paused", err)
+	}
+
+	if _, _, err := m.AcceptFrom(); !errors.Is(err, ErrPaused) {
+		t.Error("AcceptFrom should error while paused", err)
+	}
+}
+
+// TestDrain tests that new conns get the drain response until Resume.
+func TestDrain(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithDrainResponder(func(c net.Conn) {
+		c.Write([]byte("draining"))
+	}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	ml := m.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	ml.Drain()
+
+	client, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	msg, err := io.ReadAll(client)
+	if err != nil || string(msg) != "draining" {
+		t.Error("drained conn should get the response and be closed", err, string(msg))
+	}
+
+	if n := ml.Drained(); n != 1 {
+		t.Error("one conn should be drained", n)
+	}
+
+	ml.Resume()
+
+	client, err = net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer client.Close()
+
+	c, err := ml.AcceptTimeout(time.Second)
+	if err != nil {
+		t.Fatal("conns should be handed out after resume", err)
+	}
+	c.Close()
+}
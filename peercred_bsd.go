@@ -0,0 +1,24 @@
+//go:build darwin || freebsd
+
+package multilistener
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Socket options of SOL_LOCAL from sys/un.h, which the syscall package does not define.
+const (
+	solLocal      = 0
+	localPeerCred = 0x1
+)
+
+// getsockopt reads the SOL_LOCAL option name of fd into the size bytes at val.
+func getsockopt(fd uintptr, name int, val unsafe.Pointer, size uintptr) error {
+	n := uint32(size)
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, solLocal, uintptr(name), uintptr(val), uintptr(unsafe.Pointer(&n)), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
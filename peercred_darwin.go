@@ -0,0 +1,39 @@
+package multilistener
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// localPeerPID is LOCAL_PEERPID from sys/un.h.
+const localPeerPID = 0x2
+
+// xucred mirrors struct xucred from sys/ucred.h.
+type xucred struct {
+	version uint32
+	uid     uint32
+	ngroups int16
+	groups  [16]uint32
+}
+
+// peerCred reads LOCAL_PEERCRED and LOCAL_PEERPID from raw.
+func peerCred(raw syscall.RawConn) (*PeerCredentials, error) {
+	var cred xucred
+	var pid int32
+	var credErr error
+
+	err := raw.Control(func(fd uintptr) {
+		credErr = getsockopt(fd, localPeerCred, unsafe.Pointer(&cred), unsafe.Sizeof(cred))
+		if credErr == nil {
+			credErr = getsockopt(fd, localPeerPID, unsafe.Pointer(&pid), unsafe.Sizeof(pid))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	return &PeerCredentials{PID: int(pid), UID: int(cred.uid), GID: int(cred.groups[0])}, nil
+}
@@ -0,0 +1,35 @@
+package multilistener
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// xucred mirrors struct xucred from sys/ucred.h. The pid shares a union with a pointer, so
+// it is aligned like one. It is only filled in since FreeBSD 13 and reads as 0 before.
+type xucred struct {
+	version uint32
+	uid     uint32
+	ngroups int16
+	groups  [16]uint32
+	_       [0]uintptr
+	pid     int32
+}
+
+// peerCred reads LOCAL_PEERCRED from raw.
+func peerCred(raw syscall.RawConn) (*PeerCredentials, error) {
+	var cred xucred
+	var credErr error
+
+	err := raw.Control(func(fd uintptr) {
+		credErr = getsockopt(fd, localPeerCred, unsafe.Pointer(&cred), unsafe.Sizeof(cred))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	return &PeerCredentials{PID: int(cred.pid), UID: int(cred.uid), GID: int(cred.groups[0])}, nil
+}
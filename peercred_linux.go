@@ -0,0 +1,21 @@
+package multilistener
+
+import "syscall"
+
+// peerCred reads SO_PEERCRED from raw.
+func peerCred(raw syscall.RawConn) (*PeerCredentials, error) {
+	var cred *syscall.Ucred
+	var credErr error
+
+	err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	return &PeerCredentials{PID: int(cred.Pid), UID: int(cred.Uid), GID: int(cred.Gid)}, nil
+}
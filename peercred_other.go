@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package multilistener
+
+import "syscall"
+
+// peerCred is not supported on this platform.
+func peerCred(raw syscall.RawConn) (*PeerCredentials, error) {
+	return nil, ErrPeerCredUnsupported
+}
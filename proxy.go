@@ -0,0 +1,372 @@
+package multilistener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrProxyHeader is returned from reads on conns from a WithProxyProtocol listener that did
+// not start with a valid PROXY protocol header.
+var ErrProxyHeader = errors.New("invalid PROXY protocol header")
+
+// proxyV1Max is the longest v1 header, including its CRLF.
+const proxyV1Max = 107
+
+// proxyV2Sig starts every v2 header.
+var proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// defaultProxyTimeout is how long WithProxyProtocol waits for a header unless
+// WithProxyHeaderTimeout says otherwise.
+const defaultProxyTimeout = 10 * time.Second
+
+// WithProxyProtocol reads a PROXY protocol v1 or v2 header, as sent by HAProxy and most cloud
+// load balancers, from each conn of the listeners bound to addresses, or of every listener if
+// none are given. Once it is read, RemoteAddr and LocalAddr of the conn return the addresses
+// of the client and of the load balancer's listener it connected to. Headers without
+// addresses, such as v1 UNKNOWN and v2 LOCAL, leave them unchanged.
+//
+// The header is read on the first Read, from the handler's routine rather than the accept
+// routine, so slow clients do not hold up the listener, and within the read deadline of the
+// conn. RemoteAddr and LocalAddr never wait for it: until then they return the addresses of
+// the TCP conn, so servers that look them up before reading, like net/http does for
+// Request.RemoteAddr, see the load balancer. The header is read before TLS. A missing or
+// invalid header fails reads with ErrProxyHeader, which is also reported through the error
+// hook. Only use this behind a load balancer, since clients that can connect directly can
+// claim any address.
+func WithProxyProtocol(addresses ...string) Option {
+	return func(o *options) {
+		if len(addresses) == 0 {
+			o.proxyAll = true
+			return
+		}
+
+		if o.proxyAddrs == nil {
+			o.proxyAddrs = map[string]bool{}
+		}
+
+		for _, address := range addresses {
+			o.proxyAddrs[address] = true
+		}
+	}
+}
+
+// WithProxyHeaderTimeout fails conns of WithProxyProtocol listeners whose header has not
+// arrived d after the first Read, instead of after 10 seconds. A negative d leaves it to the
+// read deadline of the conn alone.
+func WithProxyHeaderTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.proxyTimeout = d
+	}
+}
+
+// proxyProtocol reports whether the listener bound to address reads PROXY headers.
+func (o options) proxyProtocol(address string) bool {
+	return o.proxyAll || o.proxyAddrs[address]
+}
+
+// proxyAddrs are the addresses carried by a PROXY header.
+type proxyAddrs struct {
+	remote net.Addr
+	local  net.Addr
+}
+
+// proxyConn reads the PROXY header of a conn before handing out its data and addresses.
+type proxyConn struct {
+	wrappedConn
+	r       *bufio.Reader
+	timeout time.Duration
+	report  func(error)
+
+	once  sync.Once
+	err   error
+	addrs atomic.Pointer[proxyAddrs]
+
+	// deadline is the read deadline set by the caller, restored after the header is read, and
+	// headerDeadline the one of the header while it is being read.
+	deadlineMut    *sync.Mutex
+	deadline       time.Time
+	headerDeadline time.Time
+}
+
+// newProxyConn wraps c of listener l.
+func (m *multiListener) newProxyConn(l *managedListener, c net.Conn) *proxyConn {
+	timeout := m.opts.proxyTimeout
+	if timeout == 0 {
+		timeout = defaultProxyTimeout
+	}
+
+	return &proxyConn{
+		wrappedConn: wrappedConn{Conn: c},
+		r:           bufio.NewReader(c),
+		timeout:     timeout,
+		report: func(err error) {
+			m.report(l.addr, err)
+		},
+		deadlineMut: &sync.Mutex{},
+	}
+}
+
+// header reads the PROXY header once and returns the error it failed with. The deadlines
+// can still be moved while it reads. Failing to set the header timeout, or to restore the
+// caller's deadline after it, fails the header as well.
+func (c *proxyConn) header() error {
+	c.once.Do(func() {
+		var err error
+
+		c.deadlineMut.Lock()
+		if c.timeout > 0 {
+			c.headerDeadline = time.Now().Add(c.timeout)
+			err = c.Conn.SetReadDeadline(c.readDeadline())
+		}
+		c.deadlineMut.Unlock()
+
+		var remote, local net.Addr
+		if err == nil {
+			remote, local, err = readProxyHeader(c.r)
+		}
+
+		c.deadlineMut.Lock()
+		if c.timeout > 0 {
+			c.headerDeadline = time.Time{}
+			if restoreErr := c.Conn.SetReadDeadline(c.deadline); err == nil {
+				err = restoreErr
+			}
+		}
+		c.deadlineMut.Unlock()
+
+		if err != nil {
+			if !errors.Is(err, ErrProxyHeader) {
+				err = &proxyReadError{err: err}
+			}
+			c.err = err
+			c.report(err)
+			return
+		}
+
+		c.addrs.Store(&proxyAddrs{remote: remote, local: local})
+	})
+
+	return c.err
+}
+
+// proxyReadError is a failure to read a PROXY header off the conn. It is a net.Error, so that
+// servers such as net/http tell a timeout apart from a bad request.
+type proxyReadError struct {
+	err error
+}
+
+// Error implements error.
+func (e *proxyReadError) Error() string {
+	return ErrProxyHeader.Error() + ": " + e.err.Error()
+}
+
+// Unwrap returns ErrProxyHeader and the read error.
+func (e *proxyReadError) Unwrap() []error {
+	return []error{ErrProxyHeader, e.err}
+}
+
+// Timeout implements net.Error.
+func (e *proxyReadError) Timeout() bool {
+	var netErr net.Error
+	return errors.As(e.err, &netErr) && netErr.Timeout()
+}
+
+// Temporary implements net.Error.
+func (e *proxyReadError) Temporary() bool {
+	return e.Timeout()
+}
+
+// readDeadline is the earlier of the caller's and the header's deadline. The caller must hold
+// deadlineMut.
+func (c *proxyConn) readDeadline() time.Time {
+	if c.headerDeadline.IsZero() || (!c.deadline.IsZero() && c.deadline.Before(c.headerDeadline)) {
+		return c.deadline
+	}
+	return c.headerDeadline
+}
+
+// Read implements net.Conn, reading the header first.
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if err := c.header(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// RemoteAddr returns the client address from the header once it was read.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if addrs := c.addrs.Load(); addrs != nil && addrs.remote != nil {
+		return addrs.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the address the client connected to from the header once it was read.
+func (c *proxyConn) LocalAddr() net.Addr {
+	if addrs := c.addrs.Load(); addrs != nil && addrs.local != nil {
+		return addrs.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// SetDeadline implements net.Conn, remembering the read deadline for after the header.
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.deadlineMut.Lock()
+	defer c.deadlineMut.Unlock()
+
+	c.deadline = t
+	err := c.Conn.SetWriteDeadline(t)
+	return errors.Join(err, c.Conn.SetReadDeadline(c.readDeadline()))
+}
+
+// SetReadDeadline implements net.Conn, remembering the deadline for after the header.
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMut.Lock()
+	defer c.deadlineMut.Unlock()
+
+	c.deadline = t
+	return c.Conn.SetReadDeadline(c.readDeadline())
+}
+
+// readProxyHeader reads a v1 or v2 PROXY header from r. The addresses are nil for headers
+// that do not carry any.
+func readProxyHeader(r *bufio.Reader) (remote net.Addr, local net.Addr, err error) {
+	// Every v1 header is longer than the v2 signature.
+	start, err := r.Peek(len(proxyV2Sig))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case bytes.Equal(start, proxyV2Sig):
+		return readProxyV2(r)
+	case bytes.HasPrefix(start, []byte("PROXY ")):
+		return readProxyV1(r)
+	}
+	return nil, nil, fmt.Errorf("%w: no PROXY signature", ErrProxyHeader)
+}
+
+// readProxyV1 reads a human readable v1 header, such as "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443".
+func readProxyV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	line := []byte{}
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) == proxyV1Max {
+			return nil, nil, fmt.Errorf("%w: v1 header is too long", ErrProxyHeader)
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("%w: malformed v1 header %q", ErrProxyHeader, line)
+	}
+
+	remote, err := proxyV1Addr(fields[1], fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local, err := proxyV1Addr(fields[1], fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return remote, local, nil
+}
+
+// proxyV1Addr parses the address of a v1 header of protocol proto.
+func proxyV1Addr(proto string, ip string, port string) (net.Addr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || strings.Contains(ip, ":") == (proto == "TCP4") {
+		return nil, fmt.Errorf("%w: invalid %s address %q", ErrProxyHeader, proto, ip)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, fmt.Errorf("%w: invalid port %q", ErrProxyHeader, port)
+	}
+
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+// readProxyV2 reads a binary v2 header. TLVs are skipped.
+func readProxyV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, nil, err
+	}
+
+	if hdr[12]>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrProxyHeader, hdr[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, err
+	}
+
+	switch cmd := hdr[12] & 0xf; cmd {
+	case 0:
+		// LOCAL conns come from the load balancer itself, like health checks.
+		return nil, nil, nil
+	case 1:
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported command %d", ErrProxyHeader, cmd)
+	}
+
+	family, transport := hdr[13]>>4, hdr[13]&0xf
+
+	ipAddr := func(ip []byte, port []byte) net.Addr {
+		ip = bytes.Clone(ip)
+		p := int(binary.BigEndian.Uint16(port))
+		if transport == 2 {
+			return &net.UDPAddr{IP: ip, Port: p}
+		}
+		return &net.TCPAddr{IP: ip, Port: p}
+	}
+
+	switch {
+	case family == 0:
+		return nil, nil, nil
+	case transport != 1 && transport != 2:
+		return nil, nil, fmt.Errorf("%w: unsupported transport %d", ErrProxyHeader, transport)
+	case family == 1 && len(body) >= 12:
+		return ipAddr(body[0:4], body[8:10]), ipAddr(body[4:8], body[10:12]), nil
+	case family == 2 && len(body) >= 36:
+		return ipAddr(body[0:16], body[32:34]), ipAddr(body[16:32], body[34:36]), nil
+	case family == 3 && len(body) >= 216:
+		network := "unix"
+		if transport == 2 {
+			network = "unixgram"
+		}
+		return proxyUnixAddr(network, body[0:108]), proxyUnixAddr(network, body[108:216]), nil
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported family %d with %d address bytes", ErrProxyHeader, family, len(body))
+}
+
+// proxyUnixAddr parses a NUL padded unix socket path of a v2 header.
+func proxyUnixAddr(network string, path []byte) net.Addr {
+	if i := bytes.IndexByte(path, 0); i >= 0 {
+		path = path[:i]
+	}
+	return &net.UnixAddr{Name: string(path), Net: network}
+}
@@ -0,0 +1,269 @@
+package multilistener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2Header builds a v2 PROXY header for a tcp4 conn from remote to local.
+func proxyV2Header(remote *net.TCPAddr, local *net.TCPAddr) []byte {
+	b := append([]byte{}, proxyV2Sig...)
+	b = append(b, 0x21, 0x11, 0, 12)
+	b = append(b, remote.IP.To4()...)
+	b = append(b, local.IP.To4()...)
+	b = binary.BigEndian.AppendUint16(b, uint16(remote.Port))
+	return binary.BigEndian.AppendUint16(b, uint16(local.Port))
+}
+
+// TestReadProxyHeader tests parsing v1 and v2 headers.
+func TestReadProxyHeader(t *testing.T) {
+	cases := []struct {
+		header string
+		remote string
+		local  string
+	}{
+		{"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n", "192.0.2.1:56324", "192.0.2.2:443"},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", "[2001:db8::1]:56324", "[2001:db8::2]:443"},
+		{"PROXY UNKNOWN\r\n", "", ""},
+		{string(proxyV2Header(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 56324}, &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 443})), "192.0.2.1:56324", "192.0.2.2:443"},
+		{string(append(append([]byte{}, proxyV2Sig...), 0x20, 0x00, 0, 0)), "", ""},
+	}
+
+	for _, c := range cases {
+		r := bufio.NewReader(strings.NewReader(c.header + "data"))
+
+		remote, local, err := readProxyHeader(r)
+		if err != nil {
+			t.Error("error when reading a valid header", c.header, err)
+			continue
+		}
+
+		if c.remote == "" {
+			if remote != nil || local != nil {
+				t.Error("headers without addresses should not return any", c.header, remote, local)
+			}
+		} else if remote.String() != c.remote || local.String() != c.local {
+			t.Error("unexpected addresses", c.header, remote, local)
+		}
+
+		if rest, _ := io.ReadAll(r); string(rest) != "data" {
+			t.Error("the data after the header should be left unread", c.header, string(rest))
+		}
+	}
+}
+
+// TestReadProxyHeaderInvalid tests that malformed headers are rejected.
+func TestReadProxyHeaderInvalid(t *testing.T) {
+	headers := []string{
+		"GET / HTTP/1.1\r\n\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n",
+		"PROXY TCP4 2001:db8::1 192.0.2.2 56324 443\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324 65536\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324 443" + strings.Repeat(" ", proxyV1Max) + "\r\n",
+		string(append(append([]byte{}, proxyV2Sig...), 0x11, 0x11, 0, 0)),
+		string(append(append([]byte{}, proxyV2Sig...), 0x21, 0x11, 0, 4, 1, 2, 3, 4)),
+	}
+
+	for _, header := range headers {
+		_, _, err := readProxyHeader(bufio.NewReader(strings.NewReader(header)))
+		if !errors.Is(err, ErrProxyHeader) {
+			t.Error("invalid headers should return ErrProxyHeader", header, err)
+		}
+	}
+}
+
+// TestWithProxyProtocol tests that accepted conns report the addresses from the header.
+func TestWithProxyProtocol(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithProxyProtocol(), WithProxyHeaderTimeout(time.Second))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	c.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\nhello"))
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer conn.Close()
+
+	if conn.RemoteAddr().String() != c.LocalAddr().String() {
+		t.Error("the conn should have its own address before the header was read", conn.RemoteAddr())
+	}
+
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(conn, b); err != nil || string(b) != "hello" {
+		t.Error("the data after the header should be read", string(b), err)
+	}
+
+	if conn.RemoteAddr().String() != "192.0.2.1:56324" || conn.LocalAddr().String() != "192.0.2.2:443" {
+		t.Error("the conn should have the addresses from the header", conn.RemoteAddr(), conn.LocalAddr())
+	}
+}
+
+// TestWithProxyProtocolDeadline tests that a deadline set while the header is being read
+// interrupts it, and that silent clients are dropped by http.Server's timeouts.
+func TestWithProxyProtocolDeadline(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithProxyProtocol(), WithProxyHeaderTimeout(-1))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer conn.Close()
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		read <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	conn.SetReadDeadline(time.Now())
+
+	select {
+	case err := <-read:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Error("the header read should fail with the deadline", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("setting a deadline should interrupt the header read")
+	}
+
+	srv := &http.Server{ReadHeaderTimeout: 50 * time.Millisecond}
+	go srv.Serve(m)
+	t.Cleanup(func() { srv.Close() })
+
+	silent, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer silent.Close()
+
+	silent.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := silent.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Error("http.Server should drop a client that never sends a header", err)
+	}
+}
+
+// deadlineConn is a conn whose read deadline can not be set.
+type deadlineConn struct {
+	net.Conn
+	err error
+}
+
+// SetReadDeadline implements net.Conn.
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	return c.err
+}
+
+// TestProxyHeaderDeadlineError tests that the header read fails when its timeout can not be set.
+func TestProxyHeaderDeadlineError(t *testing.T) {
+	m, err := newMultiListener(WithProxyProtocol())
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"))
+
+	broken := errors.New("deadline not supported")
+	l := &managedListener{addr: &net.TCPAddr{}}
+	conn := m.newProxyConn(l, &deadlineConn{Conn: server, err: broken})
+	defer conn.Close()
+
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrProxyHeader) || !errors.Is(err, broken) {
+		t.Error("the header read should fail with the deadline error", err)
+	}
+}
+
+// TestWithProxyHeaderTimeout tests that conns whose header never arrives fail.
+func TestWithProxyHeaderTimeout(t *testing.T) {
+	reported := make(chan error, 1)
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithProxyProtocol(), WithProxyHeaderTimeout(10*time.Millisecond), WithErrorHook(func(addr net.Addr, err error) {
+		reported <- err
+	}))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	c, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	conn, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer conn.Close()
+
+	var netErr net.Error
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, ErrProxyHeader) || !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Error("a missing header should time out", err)
+	}
+
+	if err := <-reported; !errors.Is(err, ErrProxyHeader) {
+		t.Error("the failed header should be reported", err)
+	}
+
+	if conn.RemoteAddr().String() != c.LocalAddr().String() {
+		t.Error("a failed header should leave the real address", conn.RemoteAddr())
+	}
+}
+
+// FuzzProxyHeader tests that no header makes the parser panic or read past its end.
+func FuzzProxyHeader(f *testing.F) {
+	f.Add([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"))
+	f.Add([]byte("PROXY UNKNOWN\r\n"))
+	f.Add(proxyV2Header(&net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1}, &net.TCPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 2}))
+
+	f.Fuzz(func(t *testing.T, header []byte) {
+		r := bufio.NewReader(bytes.NewReader(append(header, "data"...)))
+
+		remote, local, err := readProxyHeader(r)
+		if err != nil {
+			return
+		}
+
+		if (remote == nil) != (local == nil) {
+			t.Error("headers should carry both addresses or neither", remote, local)
+		}
+	})
+}
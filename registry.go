@@ -0,0 +1,44 @@
+package multilistener
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+)
+
+// NetworkFunc creates a listener for a custom network registered with RegisterNetwork.
+type NetworkFunc func(ctx context.Context, address string) (net.Listener, error)
+
+var (
+	registryMut = &sync.RWMutex{}
+	registry    = map[string]NetworkFunc{}
+)
+
+// RegisterNetwork makes Listen dispatch the network scheme to fn, so listeners from other
+// packages, such as QUIC stream listeners, can be bound by name. Addresses for the scheme
+// are passed to fn as is. It panics if scheme is empty, fn is nil, or scheme is a built in
+// network or already registered.
+func RegisterNetwork(scheme string, fn NetworkFunc) {
+	registryMut.Lock()
+	defer registryMut.Unlock()
+
+	if scheme == "" || fn == nil {
+		panic("multilistener: RegisterNetwork needs a scheme and a func")
+	}
+
+	if _, ok := registry[scheme]; ok || slices.Contains(knownNetworks, scheme) {
+		panic("multilistener: RegisterNetwork called twice for network " + scheme)
+	}
+
+	registry[scheme] = fn
+}
+
+// registered returns the func registered for network, if any.
+func registered(network string) (NetworkFunc, bool) {
+	registryMut.RLock()
+	defer registryMut.RUnlock()
+
+	fn, ok := registry[network]
+	return fn, ok
+}
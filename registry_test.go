@@ -0,0 +1,42 @@
+package multilistener
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+// TestRegisterNetwork tests dispatching a custom network to its func.
+func TestRegisterNetwork(t *testing.T) {
+	if _, ok := registered("testscheme"); !ok {
+		RegisterNetwork("testscheme", func(ctx context.Context, address string) (net.Listener, error) {
+			return net.Listen("tcp", address)
+		})
+	}
+
+	m, err := Listen(map[string][]string{
+		"testscheme": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on a registered network", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	if info := m.(*MultiListener).Info(); info[0].Network != "testscheme" {
+		t.Error("listener should keep its registered network", info)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a built in network should panic")
+		}
+	}()
+
+	RegisterNetwork("tcp", func(ctx context.Context, address string) (net.Listener, error) {
+		return nil, nil
+	})
+}
@@ -0,0 +1,99 @@
+package multilistener
+
+import (
+	"errors"
+	"hash/fnv"
+	"net"
+	"time"
+)
+
+// maxShardBackoff caps how long the distributor of Shards waits after an accept error.
+const maxShardBackoff = time.Second
+
+// Shards starts distributing accepted conns across n channels so they can be handed to a
+// pool of workers. The shard is picked by hashing the host part of the remote address with
+// 32-bit FNV-1a modulo n, so every conn from the same client lands on the same shard. Ports
+// are ignored, and conns without a host (like most unix conns) all land on the same shard.
+//
+// Shards consumes the multilistener's conns, so it must not be combined with Accept. A slow
+// shard holds up the others. Accept errors are skipped; use WithErrorHook to observe them.
+// While they keep coming, such as after the deadline set by SetDeadline or while paused with
+// WithPauseError, the distributor backs off from 5ms up to a second.
+// Once the multilistener is closed the in-flight conn is delivered or closed and every
+// shard channel is closed.
+func (m *multiListener) Shards(n int) []<-chan net.Conn {
+	if n <= 0 {
+		return nil
+	}
+
+	shards := make([]chan net.Conn, n)
+	out := make([]<-chan net.Conn, n)
+	for i := range shards {
+		shards[i] = make(chan net.Conn)
+		out[i] = shards[i]
+	}
+
+	m.goRoutine(func() { m.distribute(shards) })
+
+	return out
+}
+
+// distribute feeds accepted conns to their shards until the multilistener is closed.
+func (m *multiListener) distribute(shards []chan net.Conn) {
+	defer func() {
+		for _, s := range shards {
+			close(s)
+		}
+	}()
+
+	var delay time.Duration
+
+	for {
+		c, err := m.Accept()
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+		if err != nil {
+			// Errors like a passed deadline come back right away and would spin.
+			delay = min(max(2*delay, initialBackoff), maxShardBackoff)
+			if !m.sleep(delay) {
+				return
+			}
+			continue
+		}
+		delay = 0
+
+		select {
+		case shards[shardOf(c.RemoteAddr(), len(shards))] <- c:
+		case <-m.stop:
+			c.Close()
+			return
+		}
+	}
+}
+
+// sleep waits for d and returns false if the multilistener is closed meanwhile.
+func (m *multiListener) sleep(d time.Duration) bool {
+	timer := m.opts.clock.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-m.stop:
+		return false
+	}
+}
+
+// shardOf hashes the host of addr onto one of n shards.
+func shardOf(addr net.Addr, n int) int {
+	key := addr.String()
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		key = host
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(n))
+}
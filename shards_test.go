@@ -0,0 +1,95 @@
+package multilistener
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestShards tests that conns from one client stick to one shard and shards close with the listener.
+func TestShards(t *testing.T) {
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	})
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	a := m.(*MultiListener)
+	shards := a.Shards(4)
+
+	if len(shards) != 4 {
+		t.Fatal("there should be a channel per shard", len(shards))
+	}
+
+	addr := a.Addresses()[0]
+	expected := shards[shardOf(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, 4)]
+
+	for i := 0; i < 3; i++ {
+		c, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatal("error connecting to listener", err)
+		}
+		defer c.Close()
+
+		select {
+		case conn := <-expected:
+			conn.Close()
+		case <-time.After(time.Second):
+			t.Fatal("conn should be delivered to the shard of its client")
+		}
+	}
+
+	m.Close()
+
+	for _, s := range shards {
+		select {
+		case _, ok := <-s:
+			if ok {
+				t.Error("no conns should be left after closing")
+			}
+		case <-time.After(time.Second):
+			t.Error("shards should be closed along with the listener")
+		}
+	}
+}
+
+// TestShardsBackoff tests that the distributor backs off instead of spinning while accept
+// fails right away, and resumes once it succeeds again.
+func TestShardsBackoff(t *testing.T) {
+	clock := newFakeClock()
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, withClock(clock))
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	a := m.(*MultiListener)
+	t.Cleanup(func() { a.Close() })
+
+	a.SetDeadline(clock.Now().Add(-time.Second))
+	shards := a.Shards(1)
+
+	// The distributor waits on a timer rather than calling Accept again.
+	clock.awaitTimers(t, 1)
+
+	a.SetDeadline(time.Time{})
+
+	c, err := net.Dial("tcp", a.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer c.Close()
+
+	clock.Advance(maxShardBackoff)
+
+	select {
+	case conn := <-shards[0]:
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("the distributor should resume after the backoff")
+	}
+}
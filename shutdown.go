@@ -0,0 +1,72 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// shutdownPoll is how often Shutdown checks whether every tracked conn was closed.
+const shutdownPoll = 10 * time.Millisecond
+
+// WithConnTracking tracks accepted conns so that Shutdown can wait for them to be closed.
+func WithConnTracking() Option {
+	return func(o *options) {
+		o.connTracking = true
+	}
+}
+
+// WithForceCloseOnShutdownTimeout makes Shutdown close the conns that are still open when its
+// context is done, instead of leaving them open. It also tracks conns like WithConnTracking.
+func WithForceCloseOnShutdownTimeout(force bool) Option {
+	return func(o *options) {
+		o.forceClose = force
+	}
+}
+
+// ForceClosedError is returned by Shutdown when its context was done before every conn
+// was closed and WithForceCloseOnShutdownTimeout closed the rest. It unwraps to the
+// context's error.
+type ForceClosedError struct {
+	Count int
+	Err   error
+}
+
+// Error implements error.
+func (e *ForceClosedError) Error() string {
+	return fmt.Sprintf("shutdown: force closed %d conns: %s", e.Count, e.Err)
+}
+
+// Unwrap returns the context's error.
+func (e *ForceClosedError) Unwrap() error {
+	return e.Err
+}
+
+// Shutdown closes every listener, like Close but without closing conns, then waits until the
+// conns it accepted are closed or ctx is done, returning the context's error in that case.
+// Only conns tracked with WithConnTracking, WithCloseConnsOnShutdown or
+// WithForceCloseOnShutdownTimeout are waited for. Shutdown may be called after Close to wait
+// for the remaining conns.
+func (m *multiListener) Shutdown(ctx context.Context) error {
+	err := m.close(false, ErrClosed)
+	if err == ErrClosed {
+		err = nil
+	}
+
+	ticker := m.opts.clock.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+
+	for m.OpenConns() > 0 {
+		select {
+		case <-ctx.Done():
+			if !m.opts.forceClose {
+				return errors.Join(err, ctx.Err())
+			}
+			return errors.Join(err, &ForceClosedError{Count: m.closeConns(), Err: ctx.Err()})
+		case <-ticker.C():
+		}
+	}
+
+	return err
+}
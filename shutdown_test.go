@@ -0,0 +1,145 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// shutdownConn listens with opts and returns the multilistener with one accepted conn.
+func shutdownConn(t *testing.T, opts ...Option) (*MultiListener, net.Conn, net.Conn) {
+	t.Helper()
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, opts...)
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	client, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return m.(*MultiListener), client, c
+}
+
+// TestShutdown tests that Shutdown waits for open conns to be closed.
+func TestShutdown(t *testing.T) {
+	m, _, c := shutdownConn(t, WithConnTracking())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatal("shutdown should wait for the open conn", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := m.Accept(); !errors.Is(err, ErrClosed) {
+		t.Error("listeners should be closed while shutting down", err)
+	}
+
+	c.Close()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("shutdown should succeed once conns are closed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after the conn was closed")
+	}
+}
+
+// TestShutdownTimeout tests that conns are left open when the context is done.
+func TestShutdownTimeout(t *testing.T) {
+	m, client, _ := shutdownConn(t, WithConnTracking())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := m.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("shutdown should return the context error", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("conn should still be open", err)
+	}
+}
+
+// TestWithForceCloseOnShutdownTimeout tests that leftover conns are closed when the context is done.
+func TestWithForceCloseOnShutdownTimeout(t *testing.T) {
+	m, client, _ := shutdownConn(t, WithForceCloseOnShutdownTimeout(true))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := m.Shutdown(ctx)
+
+	var forced *ForceClosedError
+	if !errors.As(err, &forced) || forced.Count != 1 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("shutdown should report the force closed conn", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("conn should be closed", err)
+	}
+}
+
+// TestOpenConns tests that OpenConns counts tracked conns until they are closed.
+func TestOpenConns(t *testing.T) {
+	m, client, c := shutdownConn(t, WithConnTracking())
+
+	second, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer second.Close()
+
+	d, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+
+	if n := m.OpenConns(); n != 2 {
+		t.Error("both accepted conns should be counted", n)
+	}
+
+	m.Drain()
+	c.Close()
+	client.Close()
+
+	if n := m.OpenConns(); n != 1 {
+		t.Error("closed conns should no longer be counted", n)
+	}
+
+	d.Close()
+
+	if n := m.OpenConns(); n != 0 {
+		t.Error("every conn should be closed", n)
+	}
+
+	untracked, _, _ := shutdownConn(t)
+	if n := untracked.OpenConns(); n != 0 {
+		t.Error("untracked conns should not be counted", n)
+	}
+}
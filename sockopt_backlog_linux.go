@@ -0,0 +1,11 @@
+package multilistener
+
+import "syscall"
+
+const backlogSupported = true
+
+// setBacklog resizes the accept queue of the listening socket fd. Linux lets listen(2)
+// be called again on a socket that is already listening to do so.
+func setBacklog(fd uintptr, n int) error {
+	return syscall.Listen(int(fd), n)
+}
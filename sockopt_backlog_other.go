@@ -0,0 +1,12 @@
+//go:build !linux
+
+package multilistener
+
+import "errors"
+
+const backlogSupported = false
+
+// setBacklog is not supported on this platform.
+func setBacklog(fd uintptr, n int) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,13 @@
+package multilistener
+
+import "syscall"
+
+// tcpFastOpen is TCP_FASTOPEN, which the syscall package does not define on every linux arch.
+const tcpFastOpen = 0x17
+
+const fastOpenSupported = true
+
+// setFastOpen enables TCP Fast Open on fd with a queue of qlen.
+func setFastOpen(fd uintptr, qlen int) error {
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpen, qlen)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package multilistener
+
+const fastOpenSupported = false
+
+// setFastOpen is not supported on this platform.
+func setFastOpen(fd uintptr, qlen int) error {
+	return ErrFastOpenUnsupported
+}
@@ -0,0 +1,6 @@
+//go:build linux && !(mips || mipsle || mips64 || mips64le)
+
+package multilistener
+
+// soReusePort is SO_REUSEPORT, which the syscall package does not define on every linux arch.
+const soReusePort = 0xf
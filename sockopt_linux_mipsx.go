@@ -0,0 +1,6 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package multilistener
+
+// soReusePort is SO_REUSEPORT, which uses the MIPS specific value on these arches.
+const soReusePort = 0x200
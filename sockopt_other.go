@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package multilistener
+
+const reusePortSupported = false
+
+// setReusePort is not supported on this platform.
+func setReusePort(fd uintptr) error {
+	return ErrReusePortUnsupported
+}
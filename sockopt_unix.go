@@ -0,0 +1,12 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package multilistener
+
+import "syscall"
+
+const reusePortSupported = true
+
+// setReusePort enables SO_REUSEPORT on fd.
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
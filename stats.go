@@ -0,0 +1,99 @@
+package multilistener
+
+import (
+	"errors"
+	"expvar"
+	"net"
+	"sync"
+)
+
+// ErrExpvarExists is returned by PublishExpvar when an expvar of the same name is published.
+var ErrExpvarExists = errors.New("expvar is already published")
+
+// expvarMut makes checking for and publishing an expvar a single step.
+var expvarMut sync.Mutex
+
+// ListenerStats counts what a single listener's accept routines saw.
+type ListenerStats struct {
+	Addr net.Addr
+
+	// Accepted counts the conns the listener accepted, including ones later rejected or
+	// turned away, such as by WithAcceptFilter or Drain.
+	Accepted int64
+
+	// Errors counts failed accepts and LastError is the latest of them, or nil.
+	Errors    int64
+	LastError error
+}
+
+// Stats returns the accept counters of each listener, for telling a listener that accepts
+// normally from one that keeps failing without setting up an error hook. This is not ordered.
+func (m *multiListener) Stats() []ListenerStats {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+
+	stats := []ListenerStats{}
+	for _, l := range m.listeners {
+		s := ListenerStats{
+			Addr:     l.addr,
+			Accepted: l.accepted.Load(),
+			Errors:   l.errors.Load(),
+		}
+		if err := l.lastErr.Load(); err != nil {
+			s.LastError = *err
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// count records the result of an accept of l.
+func (l *managedListener) count(err error) {
+	if err == nil {
+		l.accepted.Add(1)
+		return
+	}
+
+	// Storing a pointer to a copy keeps err itself on the stack for successful accepts.
+	last := err
+	l.errors.Add(1)
+	l.lastErr.Store(&last)
+}
+
+// PublishExpvar publishes the Stats of every listener and OpenConns as the expvar name, so
+// they show up in /debug/vars without any other dependency. Values are read whenever the var
+// is. Expvars can not be unpublished, so the multilistener stays reachable for the rest of the
+// process, with its last stats published after Close. It returns ErrExpvarExists if name is
+// already taken.
+func (m *multiListener) PublishExpvar(name string) error {
+	expvarMut.Lock()
+	defer expvarMut.Unlock()
+
+	if expvar.Get(name) != nil {
+		return ErrExpvarExists
+	}
+
+	expvar.Publish(name, expvar.Func(m.expvar))
+	return nil
+}
+
+// expvar returns what PublishExpvar publishes.
+func (m *multiListener) expvar() any {
+	listeners := map[string]any{}
+	for _, s := range m.Stats() {
+		l := map[string]any{
+			"network":  s.Addr.Network(),
+			"accepted": s.Accepted,
+			"errors":   s.Errors,
+		}
+		if s.LastError != nil {
+			l["last_error"] = s.LastError.Error()
+		}
+		listeners[s.Addr.String()] = l
+	}
+
+	return map[string]any{
+		"listeners":  listeners,
+		"open_conns": m.OpenConns(),
+	}
+}
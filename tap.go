@@ -0,0 +1,48 @@
+package multilistener
+
+import (
+	"io"
+	"sync"
+)
+
+// WithConnTap copies every byte read from accepted conns to w, each read written as one line
+// prefixed with the conn's remote address. Writes to w are serialized, so one writer can be
+// shared by every conn. Bytes are copied before TLS, so TLS listeners tap encrypted bytes.
+//
+// This is meant for debugging protocols during development: every read pays for an extra
+// copy and a lock shared by all conns, and a slow w slows down every reader.
+func WithConnTap(w io.Writer) Option {
+	return func(o *options) {
+		o.tap = &tap{w: w}
+	}
+}
+
+// tap is the writer shared by every tapped conn.
+type tap struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// tapConn copies what is read from it to a tap.
+type tapConn struct {
+	wrappedConn
+	tap *tap
+}
+
+// Read implements net.Conn.
+func (c *tapConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		line := make([]byte, 0, n+64)
+		line = append(line, c.RemoteAddr().String()...)
+		line = append(line, ": "...)
+		line = append(line, b[:n]...)
+		line = append(line, '\n')
+
+		c.tap.mu.Lock()
+		// The tap is for debugging, so a failing writer must not fail the conn it taps.
+		_, _ = c.tap.w.Write(line)
+		c.tap.mu.Unlock()
+	}
+	return n, err
+}
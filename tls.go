@@ -0,0 +1,154 @@
+package multilistener
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTLSHandshakeTimeout is reported for conns closed by WithTLSHandshakeTimeout.
+var ErrTLSHandshakeTimeout = errors.New("TLS handshake timed out")
+
+// WithTLS serves TLS with config on the listeners bound to the given addresses, or on every
+// listener when no addresses are given. Addresses are matched against the addresses passed to
+// Listen. Accepted conns are returned as *tls.Conn without performing the handshake, so
+// callbacks like GetCertificate and GetConfigForClient run when the caller handshakes.
+func WithTLS(config *tls.Config, addresses ...string) Option {
+	return func(o *options) {
+		if len(addresses) == 0 {
+			o.tlsDefault = config
+			return
+		}
+
+		if o.tlsConfigs == nil {
+			o.tlsConfigs = map[string]*tls.Config{}
+		}
+
+		for _, address := range addresses {
+			o.tlsConfigs[address] = config
+		}
+	}
+}
+
+// tlsConfig returns the TLS config for a listener bound to address, if any.
+func (o options) tlsConfig(address string) *tls.Config {
+	if config, ok := o.tlsConfigs[address]; ok {
+		return config
+	}
+	return o.tlsDefault
+}
+
+// ListenTLS listens like Listen and serves TLS on every listener, with the config in overrides
+// for the addresses it has and with config for the rest. A nil config leaves the rest plain.
+// It is a shorthand for WithTLS.
+func ListenTLS(listeners map[string][]string, config *tls.Config, overrides map[string]*tls.Config, opts ...Option) (*MultiListener, error) {
+	tlsOpts := []Option{}
+	if config != nil {
+		tlsOpts = append(tlsOpts, WithTLS(config))
+	}
+	for address, override := range overrides {
+		tlsOpts = append(tlsOpts, WithTLS(override, address))
+	}
+
+	m, err := Listen(listeners, append(tlsOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return m.(*MultiListener), nil
+}
+
+// WithTLSHandshakeTimeout closes conns of TLS listeners whose handshake has not completed
+// within d of being accepted, reporting ErrTLSHandshakeTimeout for those whose client never
+// sent anything. The clock starts at accept, so it also covers the time before the caller
+// starts the handshake, and stops once the conn is closed. Listeners without TLS are
+// unaffected.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.tlsHandshakeTimeout = d
+	}
+}
+
+// tlsServer wraps c in a TLS server conn for l, enforcing the handshake timeout if one is set.
+func (m *multiListener) tlsServer(l *managedListener, c net.Conn) *tls.Conn {
+	d := m.opts.tlsHandshakeTimeout
+	if d <= 0 {
+		return tls.Server(c, l.tlsConfig)
+	}
+
+	hc := &handshakeConn{wrappedConn: wrappedConn{Conn: c}}
+	hc.timer = m.opts.clock.AfterFunc(d, func() {
+		if hc.done.Swap(true) {
+			return
+		}
+		c.Close()
+		// A handshake that was attempted and failed is the caller's to handle.
+		if !hc.attempted.Load() {
+			m.report(l.addr, fmt.Errorf("%w after %s", ErrTLSHandshakeTimeout, d))
+		}
+	})
+
+	return tls.Server(hc, onHandshake(l.tlsConfig, hc.finish))
+}
+
+// handshakeConn is the conn under a TLS server conn while WithTLSHandshakeTimeout is used.
+type handshakeConn struct {
+	wrappedConn
+	timer clockTimer
+
+	// done is set once the handshake completed, the conn was closed or the timer fired, and
+	// attempted once the client sent anything.
+	done      atomic.Bool
+	attempted atomic.Bool
+}
+
+// Read implements net.Conn.
+func (c *handshakeConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.attempted.Store(true)
+	}
+	return n, err
+}
+
+// Close implements net.Conn, stopping the handshake timer.
+func (c *handshakeConn) Close() error {
+	c.finish()
+	return c.Conn.Close()
+}
+
+// finish stops the handshake timer.
+func (c *handshakeConn) finish() {
+	if !c.done.Swap(true) {
+		c.timer.Stop()
+	}
+}
+
+// onHandshake returns a copy of config that calls fn once a handshake has negotiated,
+// including when GetConfigForClient picks another config.
+func onHandshake(config *tls.Config, fn func()) *tls.Config {
+	config = config.Clone()
+
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		fn()
+		if verify != nil {
+			return verify(cs)
+		}
+		return nil
+	}
+
+	if get := config.GetConfigForClient; get != nil {
+		config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			next, err := get(hello)
+			if next == nil || err != nil {
+				return next, err
+			}
+			return onHandshake(next, fn), nil
+		}
+	}
+
+	return config
+}
@@ -0,0 +1,304 @@
+package multilistener
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCertificate creates a self-signed certificate for tests.
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("error generating key", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "multilistener"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("error creating certificate", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// TestWithTLS tests that only designated listeners are wrapped and the handshake is left to the caller.
+func TestWithTLS(t *testing.T) {
+	cert := testCertificate(t)
+	serverNames := make(chan string, 1)
+
+	config := &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			serverNames <- hello.ServerName
+			return &cert, nil
+		},
+	}
+
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"tcp6": {"[::1]:0"},
+	}, WithTLS(config, "127.0.0.1:0"))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	for _, addr := range m.(*MultiListener).Addresses() {
+		isTLS := addr.Network() == "tcp" && addr.(*net.TCPAddr).IP.To4() != nil
+
+		go func(addr net.Addr) {
+			c, err := net.Dial(addr.Network(), addr.String())
+			if err != nil {
+				t.Error("error connecting to listener", err)
+				return
+			}
+			defer c.Close()
+
+			if isTLS {
+				err = tls.Client(c, &tls.Config{ServerName: "example.com", InsecureSkipVerify: true}).Handshake()
+				if err != nil {
+					t.Error("error during client handshake", err)
+				}
+			}
+		}(addr)
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error accepting from listener", err)
+		}
+
+		tlsConn, ok := c.(*tls.Conn)
+		if ok != isTLS {
+			t.Error("only the designated listener should yield tls conns", addr, c)
+		}
+
+		if ok {
+			if tlsConn.ConnectionState().HandshakeComplete {
+				t.Error("handshake should not be done before the caller starts it")
+			}
+
+			err = tlsConn.Handshake()
+			if err != nil {
+				t.Error("error during server handshake", err)
+			}
+
+			if name := <-serverNames; name != "example.com" {
+				t.Error("GetCertificate should see the SNI name", name)
+			}
+		}
+
+		c.Close()
+	}
+}
+
+// TestWithTLSHandshakeTimeout tests that stalled handshakes are cut off while completed
+// ones outlive the timeout.
+func TestWithTLSHandshakeTimeout(t *testing.T) {
+	cert := testCertificate(t)
+	reported := make(chan error, 4)
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, WithTLS(&tls.Config{Certificates: []tls.Certificate{cert}}), WithTLSHandshakeTimeout(100*time.Millisecond),
+		WithErrorHook(func(addr net.Addr, err error) {
+			reported <- err
+		}))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	stalled, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer stalled.Close()
+
+	c, err := m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	if err := c.(*tls.Conn).Handshake(); err == nil {
+		t.Error("stalled handshake should fail")
+	}
+
+	select {
+	case err := <-reported:
+		if !errors.Is(err, ErrTLSHandshakeTimeout) {
+			t.Error("unexpected reported error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout should be reported")
+	}
+
+	raw, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	client := tls.Client(raw, &tls.Config{InsecureSkipVerify: true})
+	defer client.Close()
+
+	go client.Handshake()
+
+	c, err = m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+	defer c.Close()
+
+	if err := c.(*tls.Conn).Handshake(); err != nil {
+		t.Fatal("handshake should succeed", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	go client.Write([]byte("x"))
+	if _, err := c.Read(make([]byte, 1)); err != nil {
+		t.Error("completed handshakes should not be cut off", err)
+	}
+
+	if len(reported) != 0 {
+		t.Error("completed handshakes should not be reported", <-reported)
+	}
+
+	garbage, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	defer garbage.Close()
+	garbage.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+
+	c, err = m.Accept()
+	if err != nil {
+		t.Fatal("error when accepting", err)
+	}
+
+	if err := c.(*tls.Conn).Handshake(); err == nil {
+		t.Error("a handshake with garbage should fail")
+	}
+	c.Close()
+
+	time.Sleep(200 * time.Millisecond)
+
+	if len(reported) != 0 {
+		t.Error("failed handshakes should not be reported as timed out", <-reported)
+	}
+}
+
+// TestNewTLSListener tests that New composes a tls.Listener from elsewhere with plain listeners.
+func TestNewTLSListener(t *testing.T) {
+	cert := testCertificate(t)
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	secure := tls.NewListener(inner, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	m, err := New([]net.Listener{plain, secure})
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	for _, l := range []net.Listener{plain, secure} {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer c.Close()
+
+		conn, addr, err := m.AcceptFrom()
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		defer conn.Close()
+
+		if _, ok := conn.(*tls.Conn); ok != (addr == secure.Addr()) {
+			t.Error("only conns from the tls.Listener should be *tls.Conn", addr, conn)
+		}
+	}
+}
+
+// TestListenTLS tests serving TLS with a default config and a per-address override.
+func TestListenTLS(t *testing.T) {
+	cert := testCertificate(t)
+	served := make(chan string, 1)
+
+	config := func(name string) *tls.Config {
+		return &tls.Config{GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			served <- name
+			return &cert, nil
+		}}
+	}
+
+	m, err := ListenTLS(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"tcp6": {"[::1]:0"},
+	}, config("default"), map[string]*tls.Config{"[::1]:0": config("override")})
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	for _, addr := range m.Addresses() {
+		go func(addr net.Addr) {
+			c, err := tls.Dial(addr.Network(), addr.String(), &tls.Config{InsecureSkipVerify: true})
+			if err != nil {
+				t.Error("error during client handshake", err)
+				return
+			}
+			c.Close()
+		}(addr)
+
+		c, err := m.Accept()
+		if err != nil {
+			t.Fatal("error accepting from listener", err)
+		}
+
+		tlsConn, ok := c.(*tls.Conn)
+		if !ok {
+			t.Fatal("every listener should yield tls conns", addr, c)
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			t.Error("error during server handshake", err)
+		}
+		c.Close()
+
+		want := "default"
+		if addr.(*net.TCPAddr).IP.To4() == nil {
+			want = "override"
+		}
+		if name := <-served; name != want {
+			t.Error("unexpected config for the listener", addr, name)
+		}
+	}
+}
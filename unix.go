@@ -0,0 +1,201 @@
+package multilistener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// WithUnixSocketMode sets the permissions of each unix socket file created by Listen. Other
+// listeners are unaffected.
+func WithUnixSocketMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.unixMode = mode
+		o.unixModeSet = true
+	}
+}
+
+// WithUnixSocketOwner sets the owner of each unix socket file created by Listen. A uid or gid
+// of -1 leaves it unchanged. Other listeners are unaffected.
+func WithUnixSocketOwner(uid int, gid int) Option {
+	return func(o *options) {
+		o.unixUID = uid
+		o.unixGID = gid
+		o.unixOwnerSet = true
+	}
+}
+
+// WithStaleSocketRemoval removes the file of a unix socket before binding it when nothing is
+// listening on it anymore, such as one left behind by a process that crashed, instead of
+// failing with ErrAddrInUse. A socket is stale when connecting to it is refused; live sockets
+// and files that are not sockets are left alone. Abstract sockets have no file to remove.
+func WithStaleSocketRemoval() Option {
+	return func(o *options) {
+		o.unixStale = true
+	}
+}
+
+// unixSocket is a unix listener that was bound under a temporary name and linked into place.
+type unixSocket struct {
+	*net.UnixListener
+	addr *net.UnixAddr
+	keep atomic.Bool
+}
+
+// SetUnlinkOnClose sets whether Close removes the socket file, which it does by default.
+func (u *unixSocket) SetUnlinkOnClose(unlink bool) {
+	u.keep.Store(!unlink)
+}
+
+// Addr implements net.Listener, reporting the final path instead of the temporary one.
+func (u *unixSocket) Addr() net.Addr {
+	return u.addr
+}
+
+// Close implements net.Listener and removes the socket file.
+func (u *unixSocket) Close() error {
+	err := u.UnixListener.Close()
+	if !u.keep.Load() {
+		os.Remove(u.addr.Name)
+	}
+	return err
+}
+
+// listenUnix binds a unix socket with the configured mode and owner. Setting them after
+// bind(2) would leave a window where the socket is reachable with umask permissions, so the
+// socket is bound inside a private directory next to address, adjusted there and then hard
+// linked into place. Linking fails if address already exists, like bind(2) does.
+func (m *multiListener) listenUnix(ctx context.Context, lc net.ListenConfig, network string, address string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(address), ".multilistener-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, filepath.Base(address))
+
+	nL, err := lc.Listen(ctx, network, tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	// The temporary name is removed with dir, so only the final path is left to unlink on Close.
+	ul := nL.(*net.UnixListener)
+
+	err = m.opts.chownUnix(tmp)
+	if err == nil {
+		err = os.Link(tmp, address)
+	}
+
+	if err != nil {
+		ul.Close()
+
+		if errors.Is(err, fs.ErrExist) {
+			err = fmt.Errorf("%w: %w", ErrAddrInUse, err)
+		}
+		return nil, err
+	}
+
+	return &unixSocket{
+		UnixListener: ul,
+		addr:         &net.UnixAddr{Name: address, Net: network},
+	}, nil
+}
+
+// chownUnix applies the configured mode and owner to the socket file at path.
+func (o options) chownUnix(path string) error {
+	if o.unixModeSet {
+		err := os.Chmod(path, o.unixMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.unixOwnerSet {
+		return os.Chown(path, o.unixUID, o.unixGID)
+	}
+
+	return nil
+}
+
+// removeStale removes the unix socket file at address if connecting to it is refused. The
+// bind reports whatever else is in the way.
+func (m *multiListener) removeStale(ctx context.Context, network string, address string) {
+	fi, err := os.Lstat(address)
+	if err != nil || fi.Mode()&fs.ModeSocket == 0 {
+		return
+	}
+
+	d := net.Dialer{Timeout: time.Second}
+	c, err := d.DialContext(ctx, network, address)
+	if err == nil {
+		c.Close()
+		return
+	}
+
+	if !slices.ContainsFunc(staleErrnos, func(errno error) bool { return errors.Is(err, errno) }) {
+		return
+	}
+
+	err = os.Remove(address)
+	if m.opts.logger != nil {
+		m.opts.logger.Info("removed stale unix socket", "network", network, "address", address, "error", err)
+	}
+}
+
+// unixFile reports whether a socket bound to address on network has a file, unlike abstract ones.
+func unixFile(network string, address string) bool {
+	return (network == "unix" || network == "unixpacket") && !strings.HasPrefix(address, "@")
+}
+
+// unixPerms reports whether a unix socket bound to address needs its mode or owner set.
+// Abstract sockets have no file to adjust.
+func (o options) unixPerms(network string, address string) bool {
+	return unixFile(network, address) && (o.unixModeSet || o.unixOwnerSet)
+}
+
+// ErrNotUnixConn is returned by PeerCred for conns that are not unix socket conns.
+var ErrNotUnixConn = errors.New("not a unix socket conn")
+
+// ErrPeerCredUnsupported is returned by PeerCred on platforms where it can not read peer credentials.
+var ErrPeerCredUnsupported = errors.New("peer credentials are not supported on this platform")
+
+// PeerCredentials identifies the process on the other end of a unix socket conn, as of when
+// it connected.
+type PeerCredentials struct {
+	PID int
+	UID int
+	GID int
+}
+
+// PeerCred returns the credentials of the peer of the unix socket conn c, looking through
+// any wrappers like *tls.Conn, so a local admin socket can authorize its clients without a
+// password. They are read with SO_PEERCRED on linux and LOCAL_PEERCRED on darwin and
+// freebsd; elsewhere it returns ErrPeerCredUnsupported. Other conns return ErrNotUnixConn.
+func PeerCred(c net.Conn) (*PeerCredentials, error) {
+	for c != nil {
+		if uc, ok := c.(*net.UnixConn); ok {
+			raw, err := uc.SyscallConn()
+			if err != nil {
+				return nil, err
+			}
+			return peerCred(raw)
+		}
+
+		nc, ok := c.(netConner)
+		if !ok {
+			break
+		}
+		c = nc.NetConn()
+	}
+
+	return nil, ErrNotUnixConn
+}
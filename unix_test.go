@@ -0,0 +1,188 @@
+//go:build !windows && !plan9
+
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestWithUnixSocketMode tests that unix sockets are created with the given mode and owner.
+func TestWithUnixSocketMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mode.sock")
+
+	m, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"unix": {path},
+	}, WithUnixSocketMode(0o660), WithUnixSocketOwner(os.Getuid(), os.Getgid()))
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal("socket file should exist at the requested path", err)
+	}
+
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o660 {
+		t.Error("unexpected socket mode", info.Mode())
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		t.Error("unexpected socket owner", stat.Uid)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Error("temporary files should be removed", entries)
+	}
+
+	if _, ok := m.(*MultiListener).FindAddr("unix", path); !ok {
+		t.Error("listener should report the requested path", m.Addr())
+	}
+
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal("error when dialing", err)
+	}
+	c.Close()
+
+	m.Close()
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("socket file should be removed on close", err)
+	}
+}
+
+// TestWithUnixSocketModeInUse tests that an existing socket path is not replaced.
+func TestWithUnixSocketModeInUse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "inuse.sock")
+
+	err := os.WriteFile(path, nil, 0o600)
+	if err != nil {
+		t.Fatal("error when creating file", err)
+	}
+
+	_, err = Listen(map[string][]string{
+		"unix": {path},
+	}, WithUnixSocketMode(0o600))
+	if !errors.Is(err, ErrAddrInUse) {
+		t.Error("binding over an existing file should fail with ErrAddrInUse", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Error("temporary files should be removed after a failed bind", entries)
+	}
+}
+
+// TestWithStaleSocketRemoval tests that sockets nothing listens on are replaced while live
+// ones are left alone.
+func TestWithStaleSocketRemoval(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithStaleSocketRemoval()},
+		{WithStaleSocketRemoval(), WithUnixSocketMode(0o600)},
+	} {
+		path := filepath.Join(t.TempDir(), "stale.sock")
+
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal("error when listening", err)
+		}
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+
+		_, err = Listen(map[string][]string{
+			"unix": {path},
+		})
+		if !errors.Is(err, ErrAddrInUse) {
+			t.Error("a stale socket should be in use without the option", err)
+		}
+
+		m, err := Listen(map[string][]string{
+			"unix": {path},
+		}, opts...)
+		if err != nil {
+			t.Fatal("a stale socket should be removed before binding", err)
+		}
+
+		_, err = Listen(map[string][]string{
+			"unix": {path},
+		}, opts...)
+		if !errors.Is(err, ErrAddrInUse) {
+			t.Error("a live socket should not be removed", err)
+		}
+
+		c, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal("the live socket should still accept", err)
+		}
+		c.Close()
+
+		m.Close()
+
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Error("socket file should be removed on close", err)
+		}
+	}
+}
+
+// TestPeerCred tests reading the credentials of the peer of a unix socket conn.
+func TestPeerCred(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cred.sock")
+
+	l, err := Listen(map[string][]string{
+		"tcp":  {"127.0.0.1:0"},
+		"unix": {path},
+	}, WithConnTracking())
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	m := l.(*MultiListener)
+	t.Cleanup(func() { m.Close() })
+
+	for _, network := range []string{"unix", "tcp"} {
+		addr := path
+		if network == "tcp" {
+			tcpAddr, _ := m.FindAddr("tcp", "127.0.0.1:0")
+			addr = tcpAddr.String()
+		}
+
+		c, err := net.Dial(network, addr)
+		if err != nil {
+			t.Fatal("error when dialing", err)
+		}
+		defer c.Close()
+
+		conn, err := m.AcceptTimeout(time.Second)
+		if err != nil {
+			t.Fatal("error when accepting", err)
+		}
+		defer conn.Close()
+
+		cred, err := PeerCred(conn)
+		switch {
+		case network == "tcp":
+			if !errors.Is(err, ErrNotUnixConn) {
+				t.Error("tcp conns should have no peer credentials", err)
+			}
+		case runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd":
+			if !errors.Is(err, ErrPeerCredUnsupported) {
+				t.Error("peer credentials should be unsupported", err)
+			}
+		case err != nil:
+			t.Error("error reading peer credentials", err)
+		case cred.PID != os.Getpid() || cred.UID != os.Getuid() || cred.GID != os.Getgid():
+			t.Error("unexpected peer credentials", cred)
+		}
+	}
+}
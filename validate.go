@@ -0,0 +1,173 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrDualStackConflict is returned when a dual-stack bind overlaps another bind on the same port.
+var ErrDualStackConflict = errors.New("dual-stack conflict")
+
+// pair is a single network/address combination to bind.
+type pair struct {
+	network string
+	address string
+}
+
+// pairsOf flattens a network->[]address map. Networks are sorted so binding, and the
+// errors it produces, are deterministic, while addresses keep their given order.
+func pairsOf(listeners map[string][]string) []pair {
+	networks := make([]string, 0, len(listeners))
+	for network := range listeners {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	pairs := []pair{}
+	for _, network := range networks {
+		for _, address := range listeners[network] {
+			pairs = append(pairs, pair{network: network, address: address})
+		}
+	}
+	return pairs
+}
+
+// checkNetworks reports every unknown network at once. The returned error names them all,
+// sorted, in its Network field.
+func checkNetworks(listeners map[string][]string) error {
+	unknown := []string{}
+	for network := range listeners {
+		if _, ok := registered(network); !ok && !slices.Contains(knownNetworks, network) {
+			unknown = append(unknown, network)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+
+	return &ListenError{
+		Network: strings.Join(unknown, ","),
+		Err:     fmt.Errorf("%w %s, expected one of %s", ErrUnknownNetwork, strings.Join(unknown, ", "), strings.Join(knownNetworks, ", ")),
+	}
+}
+
+// checkDualStack finds tcp binds that are certain to collide on a dual-stack host.
+// A "tcp" bind on a wildcard host is served by a single IPv6 socket that also accepts
+// IPv4, so any other tcp bind on the same port fails once it is in place. "tcp6" binds
+// are IPv6 only and do not take the IPv4 side.
+func checkDualStack(pairs []pair) error {
+	dualStack := map[string]pair{}
+	for _, p := range pairs {
+		host, port, err := net.SplitHostPort(p.address)
+		if p.network != "tcp" || err != nil || port == "0" {
+			continue
+		}
+
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			if _, ok := dualStack[port]; !ok {
+				dualStack[port] = p
+			}
+		}
+	}
+
+	for _, p := range pairs {
+		_, port, err := net.SplitHostPort(p.address)
+		if !strings.HasPrefix(p.network, "tcp") || err != nil {
+			continue
+		}
+
+		if d, ok := dualStack[port]; ok && d != p {
+			return &ListenError{
+				Network: p.network,
+				Address: p.address,
+				Err:     fmt.Errorf("%w: %s %s already covers IPv4 and IPv6 on port %s", ErrDualStackConflict, d.network, d.address, port),
+			}
+		}
+	}
+
+	return nil
+}
+
+// knownNetworks are the stream networks that can be passed to Listen.
+var knownNetworks = []string{"tcp", "tcp4", "tcp6", "unix", "unixpacket"}
+
+// validate checks that address has the right shape for network before anything is bound.
+func validate(network string, address string) error {
+	var err error
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		err = validateTCP(network, address)
+	case "unix", "unixpacket":
+		err = validateUnix(address)
+	default:
+		if _, ok := registered(network); ok {
+			return nil
+		}
+		err = fmt.Errorf("%w %q, expected one of %s", ErrUnknownNetwork, network, strings.Join(knownNetworks, ", "))
+	}
+
+	if err != nil {
+		return &ListenError{Network: network, Address: address, Err: err}
+	}
+
+	return nil
+}
+
+// validateTCP checks that address is a host:port pair usable with the given tcp network.
+func validateTCP(network string, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		if strings.Contains(address, "/") {
+			return fmt.Errorf("%w: %q looks like a unix socket path, use the unix network", ErrInvalidAddress, address)
+		}
+		return fmt.Errorf("%w: expected host:port: %s", ErrInvalidAddress, err)
+	}
+
+	if n, err := strconv.Atoi(port); err == nil && (n < 0 || n > 65535) {
+		return fmt.Errorf("%w: port %d is out of range", ErrInvalidAddress, n)
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		// Not an IP literal, leave host names to the resolver.
+		return nil
+	}
+
+	switch {
+	case network == "tcp4" && !ip.Unmap().Is4():
+		return fmt.Errorf("%w: %s is not an IPv4 address but the network is tcp4", ErrInvalidAddress, host)
+	case network == "tcp6" && ip.Is4():
+		return fmt.Errorf("%w: %s is not an IPv6 address but the network is tcp6", ErrInvalidAddress, host)
+	}
+
+	return nil
+}
+
+// validateUnix checks that address is usable as a unix socket path.
+func validateUnix(address string) error {
+	if address == "" {
+		return fmt.Errorf("%w: unix socket path is empty", ErrInvalidAddress)
+	}
+
+	if strings.Contains(address, "/") {
+		return nil
+	}
+
+	if _, port, err := net.SplitHostPort(address); err == nil {
+		if _, err := strconv.Atoi(port); err == nil {
+			return fmt.Errorf("%w: %q looks like host:port, use a tcp network", ErrInvalidAddress, address)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// TestValidate tests the address shape checks for each network.
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		network string
+		address string
+		err     error
+	}{
+		{"tcp", "127.0.0.1:8080", nil},
+		{"tcp", "localhost:http", nil},
+		{"tcp", ":0", nil},
+		{"tcp4", "0.0.0.0:8080", nil},
+		{"tcp6", "[::1]:8080", nil},
+		{"tcp6", "[fe80::1%lo]:8080", nil},
+		{"unix", "/tmp/multilistener.sock", nil},
+		{"unixpacket", "@abstract", nil},
+		{"tcp", "/tmp/multilistener.sock", ErrInvalidAddress},
+		{"tcp", "127.0.0.1", ErrInvalidAddress},
+		{"tcp", "127.0.0.1:99999", ErrInvalidAddress},
+		{"tcp4", "[::1]:8080", ErrInvalidAddress},
+		{"tcp6", "127.0.0.1:8080", ErrInvalidAddress},
+		{"unix", "", ErrInvalidAddress},
+		{"unix", "127.0.0.1:8080", ErrInvalidAddress},
+		{"foobar", "baz", ErrUnknownNetwork},
+	}
+
+	for _, c := range cases {
+		err := validate(c.network, c.address)
+		if c.err == nil {
+			if err != nil {
+				t.Error("valid address should pass", c.network, c.address, err)
+			}
+			continue
+		}
+
+		var listenErr *ListenError
+		if !errors.As(err, &listenErr) || !errors.Is(err, c.err) {
+			t.Error("invalid address should return a listen error", c.network, c.address, err)
+		}
+
+		if listenErr != nil && (listenErr.Network != c.network || listenErr.Address != c.address) {
+			t.Error("listen error should point at the mismatched pair", listenErr)
+		}
+	}
+}
+
+// TestDualStackConflict tests that overlapping dual-stack binds are rejected before binding.
+func TestDualStackConflict(t *testing.T) {
+	_, err := Listen(map[string][]string{
+		"tcp":  {":8080"},
+		"tcp4": {"127.0.0.1:8080"},
+	})
+
+	var listenErr *ListenError
+	if !errors.As(err, &listenErr) || !errors.Is(err, ErrDualStackConflict) {
+		t.Fatal("overlapping dual-stack binds should conflict", err)
+	}
+
+	if listenErr.Network != "tcp4" || listenErr.Address != "127.0.0.1:8080" {
+		t.Error("conflict should point at the overlapping bind", listenErr)
+	}
+
+	err = checkDualStack([]pair{{"tcp", ":8080"}, {"tcp", ":8081"}, {"tcp4", "0.0.0.0:8082"}, {"tcp6", "[::]:8082"}})
+	if err != nil {
+		t.Error("different ports and single stack binds should not conflict", err)
+	}
+}
+
+// TestDualStackConflictRegistered tests that listeners registered up front, like activated
+// sockets, are closed when the addresses to bind along with them conflict.
+func TestDualStackConflictRegistered(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error when listening", err)
+	}
+	defer l.Close()
+
+	m, err := newMultiListener()
+	if err != nil {
+		t.Fatal("error creating multilistener", err)
+	}
+
+	m.mut.Lock()
+	m.register(l, "tcp", l.Addr().String(), true)
+	m.mut.Unlock()
+
+	err = m.listenPairs([]pair{{"tcp", ":8080"}, {"tcp4", "127.0.0.1:8080"}}, nil)
+	if !errors.Is(err, ErrDualStackConflict) {
+		t.Fatal("overlapping dual-stack binds should conflict", err)
+	}
+
+	if len(m.listeners) != 0 {
+		t.Error("registered listeners should be dropped", m.listeners)
+	}
+
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Error("registered listeners should be closed", err)
+	}
+}
+
+// TestCheckNetworks tests that every unknown network is reported at once and in order.
+func TestCheckNetworks(t *testing.T) {
+	_, err := Listen(map[string][]string{
+		"tcp":    {"127.0.0.1:0"},
+		"foobar": {"baz"},
+		"bazqux": {"baz"},
+	})
+
+	var listenErr *ListenError
+	if !errors.As(err, &listenErr) || !errors.Is(err, ErrUnknownNetwork) {
+		t.Fatal("unknown networks should return a listen error", err)
+	}
+
+	if listenErr.Network != "bazqux,foobar" {
+		t.Error("every unknown network should be listed in order", listenErr.Network)
+	}
+}
+
+// FuzzListen tests that the checks run before binding never panic on arbitrary input and
+// only fail with their own errors.
+func FuzzListen(f *testing.F) {
+	seeds := [][2]string{
+		{"tcp", "127.0.0.1:8080"},
+		{"tcp", ":0"},
+		{"tcp", ":8080"},
+		{"tcp6", "[fe80::1%lo]:8080"},
+		{"tcp4", "[::ffff:127.0.0.1]:8080"},
+		{"tcp", "127.0.0.1:99999"},
+		{"tcp", "[::1"},
+		{"unix", "/tmp/multilistener.sock"},
+		{"unix", "127.0.0.1:8080"},
+		{"unixpacket", "@abstract"},
+		{"foobar", "baz"},
+		{"", ""},
+	}
+	for _, s := range seeds {
+		f.Add(s[0], s[1])
+	}
+
+	f.Fuzz(func(t *testing.T, network string, address string) {
+		if err := validate(network, address); err != nil {
+			var listenErr *ListenError
+			if !errors.As(err, &listenErr) || !(errors.Is(err, ErrInvalidAddress) || errors.Is(err, ErrUnknownNetwork)) {
+				t.Error("unexpected validation error", network, address, err)
+			}
+			_ = err.Error()
+		}
+
+		if err := checkNetworks(map[string][]string{network: {address}}); err != nil && !errors.Is(err, ErrUnknownNetwork) {
+			t.Error("unexpected network error", network, err)
+		}
+
+		pairs := pairsOf(map[string][]string{network: {address, address}, "tcp": {address}})
+		if err := checkDualStack(pairs); err != nil && !errors.Is(err, ErrDualStackConflict) {
+			t.Error("unexpected dual-stack error", network, address, err)
+		}
+
+		_ = newListenError(network, address, errors.New("bind failed")).Error()
+	})
+}
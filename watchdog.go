@@ -0,0 +1,39 @@
+package multilistener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrNoActivity is reported by the watchdog for listeners that have not accepted anything.
+var ErrNoActivity = errors.New("no accept activity")
+
+// watch periodically reports listeners whose accept has not returned for d.
+// Each listener is reported once per idle period.
+func (m *multiListener) watch(d time.Duration) {
+	ticker := m.opts.clock.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C():
+			idle := []net.Addr{}
+
+			m.mut.RLock()
+			for _, l := range m.listeners {
+				if now.Sub(time.Unix(0, l.lastActivity.Load())) >= d && !l.idle.Swap(true) {
+					idle = append(idle, l.addr)
+				}
+			}
+			m.mut.RUnlock()
+
+			for _, addr := range idle {
+				m.report(addr, fmt.Errorf("%w for %s", ErrNoActivity, d))
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package multilistener
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestWithListenerWatchdog tests that an idle listener is reported once.
+func TestWithListenerWatchdog(t *testing.T) {
+	clock := newFakeClock()
+	reports := make(chan error, 10)
+
+	m, err := Listen(map[string][]string{
+		"tcp": {"127.0.0.1:0"},
+	}, withClock(clock), WithListenerWatchdog(time.Minute), WithErrorHook(func(addr net.Addr, err error) {
+		reports <- err
+	}))
+
+	if err != nil {
+		t.Fatal("error when listening on valid addresses", err)
+	}
+
+	t.Cleanup(func() {
+		m.Close()
+	})
+
+	clock.awaitTimers(t, 1)
+	clock.Advance(time.Minute)
+
+	select {
+	case err := <-reports:
+		if !errors.Is(err, ErrNoActivity) {
+			t.Error("watchdog should report no activity", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("watchdog should report an idle listener")
+	}
+
+	clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+
+	if len(reports) != 0 {
+		t.Error("an idle listener should only be reported once per idle period", len(reports))
+	}
+}
@@ -0,0 +1,126 @@
+package multilistener
+
+import (
+	"math/rand"
+	"net"
+)
+
+// WithListenerWeights biases which listener's conn Accept hands out when several listeners
+// have one ready, in proportion to their weights. It is weighted random selection among the
+// ready listeners, not strict priority: a listener with weight 3 is picked three times as
+// often as one with weight 1 while both have conns waiting, and an idle listener never holds
+// up the others.
+//
+// Weights are keyed by address, which is matched on its network and on either the bound or
+// the requested address, like WithPrimaryAddr. Listeners without a weight, and weights below
+// 1, count as 1.
+//
+// Each listener then holds at most one accepted conn, in place of WithAcceptBuffer, and joins
+// a list of ready listeners while it does. For every conn Accept sums the weights of the
+// ready listeners, draws a random number below the sum and picks the listener whose range it
+// falls in, so each pick is O(number of ready listeners).
+func WithListenerWeights(weights map[net.Addr]int) Option {
+	return func(o *options) {
+		o.weights = weights
+	}
+}
+
+// weightOf returns the weight configured for l.
+func (o options) weightOf(l *managedListener) int {
+	for a, w := range o.weights {
+		if a.Network() == l.addr.Network() && (a.String() == l.addr.String() || a.String() == l.address) {
+			return max(w, 1)
+		}
+	}
+	return 1
+}
+
+// inbox returns the channel accept results of l are sent on.
+func (m *multiListener) inbox(l *managedListener) chan<- chanMsg {
+	if l.offer != nil {
+		return l.offer
+	}
+	return m.accept
+}
+
+// offered adds l to the ready listeners once it sent a result on its inbox.
+func (m *multiListener) offered(l *managedListener) {
+	if l.offer == nil {
+		return
+	}
+
+	m.readyMut.Lock()
+	m.ready = append(m.ready, l)
+	m.readyMut.Unlock()
+
+	m.signal()
+}
+
+// signal lets one Accept caller waiting on wake take from the ready listeners. Callers pass
+// the signal on while listeners stay ready, so holding back a signal when one is pending
+// loses nothing.
+func (m *multiListener) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take takes the result of a ready listener chosen by weight, if any listener is ready.
+func (m *multiListener) take() (chanMsg, bool) {
+	res, ok, more := m.pick()
+	if more {
+		m.signal()
+	}
+	return res, ok
+}
+
+// pick takes the result of a ready listener chosen by weight and reports whether others are
+// still ready.
+func (m *multiListener) pick() (res chanMsg, ok bool, more bool) {
+	m.readyMut.Lock()
+	defer m.readyMut.Unlock()
+
+	if len(m.ready) == 0 {
+		return chanMsg{}, false, false
+	}
+
+	total := 0
+	for _, l := range m.ready {
+		total += l.weight
+	}
+
+	i := 0
+	for n := rand.Intn(total); n >= m.ready[i].weight; i++ {
+		n -= m.ready[i].weight
+	}
+
+	l := m.ready[i]
+	m.ready[i] = m.ready[len(m.ready)-1]
+	m.ready = m.ready[:len(m.ready)-1]
+
+	// A listener is only ever in the ready list after sending on its inbox.
+	return <-l.offer, true, len(m.ready) > 0
+}
+
+// discardOffers closes the conns held by ready listeners once nobody will receive them.
+func (m *multiListener) discardOffers() {
+	m.readyMut.Lock()
+	defer m.readyMut.Unlock()
+
+	for _, l := range m.ready {
+		l.discardOffer()
+	}
+	m.ready = nil
+}
+
+// discardOffer closes a conn l holds that was not taken.
+func (l *managedListener) discardOffer() {
+	select {
+	case res := <-l.offer:
+		if res.conn != nil {
+			res.conn.Close()
+		}
+	default:
+	}
+}